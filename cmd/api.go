@@ -0,0 +1,166 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+// apiClient talks to the parts of the XL-Deploy rest api that are not (yet) covered by goxldeploy
+// goxldeploy only exposes the metadata and repository services and keeps its request handling unexported,
+// so the deployment, task and package endpoints cannot be reached through GetClient.
+// it takes its connection settings from clientConfig, the same as GetClient, so both always talk to the same server
+// as the same user. Once goxldeploy grows these services the commands should move over to GetClient.
+type apiClient struct {
+	baseURL  url.URL
+	user     string
+	password string
+	client   *http.Client
+	deadline time.Time
+}
+
+// apiError is returned when XL-Deploy answers with a non 2xx status code
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("xldeploy returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("xldeploy returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// getAPIClient returns a rest client using the same connection settings as GetClient
+func getAPIClient() *apiClient {
+	return newAPIClient(clientConfig())
+}
+
+// newAPIClient returns a rest client for the given connection settings
+func newAPIClient(cfg goxldeploy.Config) *apiClient {
+	return &apiClient{
+		baseURL: url.URL{
+			Scheme: cfg.Scheme,
+			Host:   cfg.Host + ":" + strconv.Itoa(cfg.Port),
+			Path:   path.Join("/", cfg.Context, "deployit"),
+		},
+		user:     cfg.User,
+		password: cfg.Password,
+		client:   &http.Client{},
+	}
+}
+
+// withDeadline returns a copy of the client whose requests are cancelled once d has passed
+// so a hanging connection cannot outlive an overall timeout
+func (a *apiClient) withDeadline(d time.Time) *apiClient {
+	c := *a
+	c.deadline = d
+	return &c
+}
+
+// do sends in as json to the given path and decodes the json response into out
+// out may be nil when the response is of no interest
+// a *string out will also accept plain text responses (xldeploy returns task id's that way)
+func (a *apiClient) do(method, p string, q url.Values, in interface{}, out interface{}) error {
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	b, err := a.doRaw(method, p, q, "application/json", body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	if s, ok := out.(*string); ok && !json.Valid(b) {
+		*s = strings.TrimSpace(string(b))
+		return nil
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// doRaw sends body with the given content type and returns the raw response body
+func (a *apiClient) doRaw(method, p string, q url.Values, contentType string, body io.Reader) ([]byte, error) {
+	u := a.baseURL
+	u.Path = path.Join(u.Path, p)
+	if q != nil {
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if !a.deadline.IsZero() {
+		ctx, cancel := gocontext.WithDeadline(gocontext.Background(), a.deadline)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	req.SetBasicAuth(a.user, a.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	jww.TRACE.Printf("%s %s", method, u.String())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &apiError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(b))}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,292 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var repositoryApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "create or update the cis defined in a directory of files",
+	Long:  "usage: apply --dir <path>\nreads the json and yaml files in path, as written by export, and creates or updates the cis in dependency order",
+	Run:   ApplyCI,
+}
+
+// propertyChange is a property that differs between a ci file and the repository
+type propertyChange struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+func init() {
+	repositoryApplyCmd.Flags().StringVar(&dir, "dir", "", "directory to read the ci files from")
+
+	repositoryCmd.AddCommand(repositoryApplyCmd)
+}
+
+func ApplyCI(cmd *cobra.Command, args []string) {
+	var created, updated, unchanged int
+
+	if dir == "" {
+		jww.FATAL.Printf("%s: requires --dir", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+	lookup := liveTypeLookup()
+
+	cis, err := loadCIFiles(dir, lookup)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	for _, ci := range cis {
+		i, _ := ci["id"].(string)
+
+		current, err := readCI(api, i)
+		if e, ok := err.(*apiError); ok && e.StatusCode == http.StatusNotFound {
+			if err := storeCI(api, http.MethodPost, ci); err != nil {
+				jww.FATAL.Printf("%s: encounterd a fatal error creating configuration Item %s: %s", cmd.CommandPath(), i, err)
+				os.Exit(1)
+			}
+			fmt.Printf("created   %s\n", i)
+			created++
+			continue
+		}
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration item %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+
+		if current["type"] != ci["type"] {
+			jww.FATAL.Printf("%s: %s is a %v in the repository and a %v on disk", cmd.CommandPath(), i, current["type"], ci["type"])
+			os.Exit(1)
+		}
+
+		t, _ := lookup(ci["type"].(string))
+		changes := diffCI(t, current, ci)
+		if len(changes) == 0 {
+			fmt.Printf("unchanged %s\n", i)
+			unchanged++
+			continue
+		}
+
+		for k, v := range ci {
+			current[k] = v
+		}
+		if err := storeCI(api, http.MethodPut, current); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error updating configuration Item %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+		fmt.Printf("updated   %s\n", i)
+		updated++
+	}
+
+	fmt.Printf("\n%d created, %d updated, %d unchanged\n", created, updated, unchanged)
+}
+
+// loadCIFiles reads all json and yaml ci files below dir, types their properties after the metadata
+// and returns them in dependency order
+// masked passwords are left out so the value in the repository is kept
+func loadCIFiles(dir string, lookup typeLookup) ([]map[string]interface{}, error) {
+	var cis []map[string]interface{}
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return nil
+		}
+
+		ci, err := readCIFile(p)
+		if err != nil {
+			return err
+		}
+
+		i, _ := ci["id"].(string)
+		t, _ := ci["type"].(string)
+		if i == "" || t == "" {
+			return fmt.Errorf("%s: a ci requires an id and a type", p)
+		}
+
+		props := make(map[string]interface{})
+		for k, v := range ci {
+			if k == "id" || k == "type" || strings.HasPrefix(k, "$") || v == passwordMask {
+				continue
+			}
+			props[k] = v
+		}
+
+		tt, err := lookup(t)
+		if err != nil {
+			return fmt.Errorf("%s: encounterd a fatal error in retrieving metadata for %s: %s", p, t, err)
+		}
+
+		props, err = coerceProperties(tt, props)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+
+		props["id"] = i
+		props["type"] = t
+		cis = append(cis, props)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortByDependency(cis, lookup)
+}
+
+// readCIFile reads a ci from a yaml (.yaml or .yml) or json file
+func readCIFile(file string) (map[string]interface{}, error) {
+	var ci map[string]interface{}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		var y interface{}
+		if err := yaml.Unmarshal(b, &y); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		ci, _ = fromYAML(y).(map[string]interface{})
+	default:
+		if err := json.Unmarshal(b, &ci); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+	}
+
+	if ci == nil {
+		return nil, fmt.Errorf("%s does not contain a ci", file)
+	}
+
+	return ci, nil
+}
+
+// fromYAML turns the map[interface{}]interface{} yaml produces into json compatible maps
+func fromYAML(v interface{}) interface{} {
+	switch y := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range y {
+			m[fmt.Sprint(k)] = fromYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range y {
+			y[i] = fromYAML(e)
+		}
+		return y
+	}
+	return v
+}
+
+// diffCI compares the properties set in desired with the current ci
+// set kinds are compared regardless of order, masked passwords are skipped
+func diffCI(t goxldeploy.Type, current, desired map[string]interface{}) []propertyChange {
+	var changes []propertyChange
+
+	kinds := make(map[string]string)
+	for _, p := range t.Properties {
+		kinds[p.Name] = strings.ToUpper(p.Kind)
+		if p.Password {
+			kinds[p.Name] = "PASSWORD"
+		}
+	}
+
+	var names []string
+	for k := range desired {
+		if k != "id" && k != "type" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		// xldeploy only hands out passwords encrypted, so a password that is given and not masked always counts
+		// it is shown masked either way
+		if kinds[k] == "PASSWORD" {
+			if desired[k] != passwordMask && !reflect.DeepEqual(desired[k], current[k]) {
+				changes = append(changes, propertyChange{Name: k, Old: passwordMask, New: passwordMask})
+			}
+			continue
+		}
+
+		o := normalizeValue(current[k], kinds[k])
+		n := normalizeValue(desired[k], kinds[k])
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, propertyChange{Name: k, Old: current[k], New: desired[k]})
+		}
+	}
+
+	return changes
+}
+
+// normalizeValue brings a value to its json form so typed and decoded values compare equal
+func normalizeValue(v interface{}, kind string) interface{} {
+	var n interface{}
+
+	b, _ := json.Marshal(v)
+	json.Unmarshal(b, &n)
+
+	if l, ok := n.([]interface{}); ok {
+		if len(l) == 0 {
+			return nil
+		}
+		if strings.HasPrefix(kind, "SET_OF_") {
+			sort.Slice(l, func(i, j int) bool { return fmt.Sprint(l[i]) < fmt.Sprint(l[j]) })
+		}
+	}
+	if m, ok := n.(map[string]interface{}); ok && len(m) == 0 {
+		return nil
+	}
+	if s, ok := n.(string); ok && s == "" {
+		return nil
+	}
+
+	return n
+}
@@ -0,0 +1,129 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/viveleroy/goxldeploy"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		kind string
+		want interface{}
+	}{
+		{nil, "STRING", nil},
+		{"", "STRING", nil},
+		{"a", "STRING", "a"},
+		{8080, "INTEGER", float64(8080)},
+		{float64(8080), "INTEGER", float64(8080)},
+		{true, "BOOLEAN", true},
+		{[]string{}, "SET_OF_STRING", nil},
+		{[]interface{}{}, "LIST_OF_STRING", nil},
+		{[]string{"b", "a"}, "SET_OF_STRING", []interface{}{"a", "b"}},
+		{[]interface{}{"b", "a"}, "SET_OF_CI", []interface{}{"a", "b"}},
+		{[]string{"b", "a"}, "LIST_OF_STRING", []interface{}{"b", "a"}},
+		{map[string]string{}, "MAP_STRING_STRING", nil},
+		{map[string]string{"k": "v"}, "MAP_STRING_STRING", map[string]interface{}{"k": "v"}},
+	}
+
+	for _, tt := range tests {
+		got := normalizeValue(tt.v, tt.kind)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeValue(%#v, %s) = %#v, want %#v", tt.v, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestDiffCI(t *testing.T) {
+	typ := goxldeploy.Type{Type: "test.Server", Properties: []goxldeploy.PropertyDescriptor{
+		{Name: "address", Kind: "STRING"},
+		{Name: "port", Kind: "INTEGER"},
+		{Name: "tags", Kind: "SET_OF_STRING"},
+		{Name: "paths", Kind: "LIST_OF_STRING"},
+		{Name: "env", Kind: "MAP_STRING_STRING"},
+		{Name: "password", Kind: "STRING", Password: true},
+	}}
+
+	current := map[string]interface{}{
+		"id":       "Infrastructure/a",
+		"type":     "test.Server",
+		"address":  "host",
+		"port":     float64(22),
+		"tags":     []interface{}{"x", "y"},
+		"paths":    []interface{}{"/a", "/b"},
+		"env":      map[string]interface{}{},
+		"password": "{b64}xxx",
+	}
+
+	tests := []struct {
+		name    string
+		desired map[string]interface{}
+		want    []propertyChange
+	}{
+		{
+			name:    "nothing set",
+			desired: map[string]interface{}{"id": "Infrastructure/a", "type": "test.Server"},
+		},
+		{
+			name:    "same values in typed form",
+			desired: map[string]interface{}{"address": "host", "port": 22, "tags": []string{"y", "x"}, "paths": []string{"/a", "/b"}, "env": map[string]string{}},
+		},
+		{
+			name:    "changed string",
+			desired: map[string]interface{}{"address": "other"},
+			want:    []propertyChange{{Name: "address", Old: "host", New: "other"}},
+		},
+		{
+			name:    "list order matters",
+			desired: map[string]interface{}{"paths": []string{"/b", "/a"}},
+			want:    []propertyChange{{Name: "paths", Old: []interface{}{"/a", "/b"}, New: []string{"/b", "/a"}}},
+		},
+		{
+			name:    "empty map gets entries",
+			desired: map[string]interface{}{"address": "host", "env": map[string]string{"k": "v"}},
+			want:    []propertyChange{{Name: "env", Old: map[string]interface{}{}, New: map[string]string{"k": "v"}}},
+		},
+		{
+			name:    "masked password",
+			desired: map[string]interface{}{"password": passwordMask},
+		},
+		{
+			name:    "unchanged encrypted password",
+			desired: map[string]interface{}{"password": "{b64}xxx"},
+		},
+		{
+			name:    "new password is shown masked",
+			desired: map[string]interface{}{"password": "newsecret"},
+			want:    []propertyChange{{Name: "password", Old: passwordMask, New: passwordMask}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := diffCI(typ, current, tt.desired)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: diffCI = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+var repositoryCopyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "copy a ci and everything below it",
+	Long:  "usage: copy <source root id> <destination root id> [--target-profile <profile>]\nreferences between the copied cis are rewritten to point to the copies, --target-profile copies to the xldeploy of a profile in the config file",
+	Run:   CopyCI,
+}
+
+var targetProfile string
+
+func init() {
+	repositoryCopyCmd.Flags().StringVar(&targetProfile, "target-profile", "", "profile from the config file to copy to")
+
+	repositoryCmd.AddCommand(repositoryCopyCmd)
+}
+
+func CopyCI(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		jww.FATAL.Printf("%s: requires a source and a destination id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	src, dst := args[0], args[1]
+
+	api := getAPIClient()
+	target := api
+
+	if targetProfile != "" {
+		cfg, err := profileConfig(targetProfile)
+		if err != nil {
+			jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+		target = newAPIClient(cfg)
+	}
+
+	cis, err := readSubtree(api, src)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), src, err)
+		os.Exit(1)
+	}
+
+	ids := make(map[string]string)
+	for _, ci := range cis {
+		i, _ := ci["id"].(string)
+		ids[i] = dst + strings.TrimPrefix(i, src)
+	}
+
+	lookup := liveTypeLookup()
+
+	var copies []map[string]interface{}
+	for _, ci := range cis {
+		c, err := rewriteReferences(ci, ids, lookup)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error copying %v: %s", cmd.CommandPath(), ci["id"], err)
+			os.Exit(1)
+		}
+		copies = append(copies, c)
+	}
+
+	copies, err = sortByDependency(copies, lookup)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	for _, ci := range copies {
+		if err := storeCI(target, http.MethodPost, ci); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error creating configuration Item %v: %s", cmd.CommandPath(), ci["id"], err)
+			os.Exit(1)
+		}
+		fmt.Println(ci["id"])
+	}
+}
+
+// readSubtree reads a ci and all cis below it
+func readSubtree(api *apiClient, root string) ([]map[string]interface{}, error) {
+	n, err := walkTree(api, root, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	var collect func(n ciNode)
+	collect = func(n ciNode) {
+		ids = append(ids, n.ID)
+		for _, c := range n.Children {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	return readCIs(api, ids)
+}
+
+// rewriteReferences returns a copy of ci with its id and every reference found in ids replaced by the mapped id
+// only the CI, SET_OF_CI and LIST_OF_CI properties of its type are references, embedded cis are rewritten the same way
+// the $ administration properties are left out as they belong to the original
+func rewriteReferences(ci map[string]interface{}, ids map[string]string, lookup typeLookup) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+
+	t, _ := ci["type"].(string)
+	tt, err := lookup(t)
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %s", t, err)
+	}
+
+	references := referenceProperties(tt)
+
+	for k, v := range ci {
+		switch {
+		case strings.HasPrefix(k, "$"):
+			continue
+		case k == "id":
+			i, _ := v.(string)
+			out[k] = copiedID(i, ids)
+		case !references[k]:
+			out[k] = v
+		default:
+			r, err := rewriteReference(v, ids, lookup)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+	}
+
+	return out, nil
+}
+
+// referenceProperties returns the names of the properties of a type that refer to other cis
+func referenceProperties(t goxldeploy.Type) map[string]bool {
+	references := make(map[string]bool)
+	for _, p := range t.Properties {
+		switch strings.ToUpper(p.Kind) {
+		case "CI", "SET_OF_CI", "LIST_OF_CI":
+			references[p.Name] = true
+		}
+	}
+	return references
+}
+
+// rewriteReference rewrites the value of a reference property, which is an id, an embedded ci or a list of those
+func rewriteReference(v interface{}, ids map[string]string, lookup typeLookup) (interface{}, error) {
+	switch r := v.(type) {
+	case string:
+		if n, ok := ids[r]; ok {
+			return n, nil
+		}
+	case map[string]interface{}:
+		return rewriteReferences(r, ids, lookup)
+	case []interface{}:
+		var nl []interface{}
+		for _, e := range r {
+			ne, err := rewriteReference(e, ids, lookup)
+			if err != nil {
+				return nil, err
+			}
+			nl = append(nl, ne)
+		}
+		return nl, nil
+	}
+	return v, nil
+}
+
+// copiedID returns the id of the copy of ci i
+// embedded cis are not read separately, their id follows the copy of the closest ancestor that was
+func copiedID(i string, ids map[string]string) string {
+	for a := i; a != "." && a != "/"; a = path.Dir(a) {
+		if n, ok := ids[a]; ok {
+			return n + strings.TrimPrefix(i, a)
+		}
+	}
+	return i
+}
+
+// sortByDependency orders cis so parents and referenced cis come before the cis depending on them
+// only dependencies within the given cis are taken into account
+func sortByDependency(cis []map[string]interface{}, lookup typeLookup) ([]map[string]interface{}, error) {
+	byID := make(map[string]map[string]interface{})
+	var ids []string
+	for _, ci := range cis {
+		i, _ := ci["id"].(string)
+		byID[i] = ci
+		ids = append(ids, i)
+	}
+	sort.Strings(ids)
+
+	var sorted []map[string]interface{}
+	state := make(map[string]int) // 1 is being visited, 2 is done
+
+	var visit func(i string) error
+	visit = func(i string) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("circular reference involving %s", i)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+
+		deps, err := dependencies(byID[i], lookup)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range deps {
+			if _, ok := byID[d]; ok {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[i] = 2
+		sorted = append(sorted, byID[i])
+		return nil
+	}
+
+	for _, i := range ids {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// dependencies returns the parent of a ci and the cis its reference properties refer to
+func dependencies(ci map[string]interface{}, lookup typeLookup) ([]string, error) {
+	i, _ := ci["id"].(string)
+
+	refs, err := referencedIDs(ci, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{path.Dir(i)}, refs...), nil
+}
+
+// referencedIDs returns the ids in the CI, SET_OF_CI and LIST_OF_CI properties of a ci, including those of its embedded cis
+func referencedIDs(ci map[string]interface{}, lookup typeLookup) ([]string, error) {
+	var ids []string
+
+	t, _ := ci["type"].(string)
+	tt, err := lookup(t)
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %s", t, err)
+	}
+
+	var collect func(v interface{}) error
+	collect = func(v interface{}) error {
+		switch r := v.(type) {
+		case string:
+			ids = append(ids, r)
+		case []string:
+			ids = append(ids, r...)
+		case []interface{}:
+			for _, e := range r {
+				if err := collect(e); err != nil {
+					return err
+				}
+			}
+		case map[string]interface{}:
+			// an embedded ci, it belongs to this ci so only its own references count
+			refs, err := referencedIDs(r, lookup)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, refs...)
+		}
+		return nil
+	}
+
+	// in metadata order, so the cis are always sorted the same way
+	references := referenceProperties(tt)
+	for _, p := range tt.Properties {
+		if !references[p.Name] {
+			continue
+		}
+		if err := collect(ci[p.Name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,129 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/viveleroy/goxldeploy"
+)
+
+// testTypeLookup resolves the given types, as a metadata snapshot would
+func testTypeLookup(types ...goxldeploy.Type) typeLookup {
+	return func(name string) (goxldeploy.Type, error) {
+		for _, t := range types {
+			if t.Type == name {
+				return t, nil
+			}
+		}
+		return goxldeploy.Type{}, fmt.Errorf("unknown type %s", name)
+	}
+}
+
+func TestSortByDependency(t *testing.T) {
+	lookup := testTypeLookup(
+		goxldeploy.Type{Type: "test.Host", Properties: []goxldeploy.PropertyDescriptor{
+			{Name: "description", Kind: "STRING"},
+		}},
+		goxldeploy.Type{Type: "test.Server", Properties: []goxldeploy.PropertyDescriptor{
+			{Name: "description", Kind: "STRING"},
+			{Name: "host", Kind: "CI"},
+			{Name: "checks", Kind: "SET_OF_CI", AsContainment: true},
+		}},
+		goxldeploy.Type{Type: "test.Check", Properties: []goxldeploy.PropertyDescriptor{
+			{Name: "target", Kind: "CI"},
+		}},
+	)
+
+	tests := []struct {
+		name string
+		cis  []map[string]interface{}
+		want []string
+	}{
+		{
+			name: "references come first",
+			cis: []map[string]interface{}{
+				{"id": "Infrastructure/a", "type": "test.Server", "host": "Infrastructure/b"},
+				{"id": "Infrastructure/b", "type": "test.Host"},
+			},
+			want: []string{"Infrastructure/b", "Infrastructure/a"},
+		},
+		{
+			name: "parents come first",
+			cis: []map[string]interface{}{
+				{"id": "Infrastructure/a/b", "type": "test.Host"},
+				{"id": "Infrastructure/a", "type": "test.Host"},
+			},
+			want: []string{"Infrastructure/a", "Infrastructure/a/b"},
+		},
+		{
+			// string properties holding an id are not references, so this is no cycle
+			name: "string properties are not references",
+			cis: []map[string]interface{}{
+				{"id": "Infrastructure/a", "type": "test.Server", "description": "Infrastructure/b", "host": "Infrastructure/b"},
+				{"id": "Infrastructure/b", "type": "test.Host", "description": "Infrastructure/a"},
+			},
+			want: []string{"Infrastructure/b", "Infrastructure/a"},
+		},
+		{
+			name: "references of embedded cis",
+			cis: []map[string]interface{}{
+				{"id": "Infrastructure/a", "type": "test.Server", "checks": []interface{}{
+					map[string]interface{}{"id": "Infrastructure/a/check", "type": "test.Check", "target": "Infrastructure/b"},
+				}},
+				{"id": "Infrastructure/b", "type": "test.Host"},
+			},
+			want: []string{"Infrastructure/b", "Infrastructure/a"},
+		},
+	}
+
+	for _, tt := range tests {
+		sorted, err := sortByDependency(tt.cis, lookup)
+		if err != nil {
+			t.Errorf("%s: returned error %s", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, ci := range sorted {
+			got = append(got, ci["id"].(string))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sorted %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortByDependencyCycle(t *testing.T) {
+	lookup := testTypeLookup(goxldeploy.Type{Type: "test.Server", Properties: []goxldeploy.PropertyDescriptor{
+		{Name: "host", Kind: "CI"},
+	}})
+
+	cis := []map[string]interface{}{
+		{"id": "Infrastructure/a", "type": "test.Server", "host": "Infrastructure/b"},
+		{"id": "Infrastructure/b", "type": "test.Server", "host": "Infrastructure/a"},
+	}
+
+	if _, err := sortByDependency(cis, lookup); err == nil {
+		t.Errorf("sortByDependency returned no error for a circular reference")
+	}
+}
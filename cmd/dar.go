@@ -0,0 +1,226 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const darManifest = "deployit-manifest.xml"
+const jarManifest = "META-INF/MANIFEST.MF"
+
+// vcsDirs are the version control directories that never belong in a dar
+var vcsDirs = map[string]bool{".git": true, ".svn": true, ".hg": true, ".bzr": true}
+
+var packageBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "build a dar package from a directory",
+	Long:  "usage: build <dir> [--out <file.dar>]\nchecks the deployit-manifest.xml in dir against the xldeploy type metadata and zips the directory into a dar",
+	Run:   buildPackage,
+}
+
+func init() {
+	packageCmd.AddCommand(packageBuildCmd)
+}
+
+func buildPackage(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a directory", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	dir := args[0]
+
+	m, err := readManifest(filepath.Join(dir, darManifest))
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), darManifest, err)
+		os.Exit(1)
+	}
+
+	application := m.attr("application")
+	version := m.attr("version")
+	if application == "" || version == "" {
+		jww.FATAL.Printf("%s: %s requires an application and a version attribute", cmd.CommandPath(), darManifest)
+		os.Exit(1)
+	}
+
+	if issues := lintManifest(m, liveTypeLookup()); len(issues) != 0 {
+		for _, i := range issues {
+			jww.ERROR.Println(i)
+		}
+		jww.FATAL.Printf("%s: %s has %d issue(s)", cmd.CommandPath(), darManifest, len(issues))
+		os.Exit(1)
+	}
+
+	mf, err := jarManifestFor(dir, application, version)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	out := outputFile
+	if out == "" {
+		out = application + "-" + version + ".dar"
+	}
+
+	err = writeDar(dir, out, mf)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error writing %s: %s", cmd.CommandPath(), out, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(out)
+}
+
+// jarManifestFor validates the MANIFEST.MF in dir or generates one when there is none
+func jarManifestFor(dir, application, version string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(jarManifest)))
+	if os.IsNotExist(err) {
+		return []byte(fmt.Sprintf("Manifest-Version: 1.0\r\nCI-Application: %s\r\nCI-Version: %s\r\n", application, version)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]string)
+	for _, l := range manifestLines(b) {
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) == 2 {
+			attrs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	if attrs["Manifest-Version"] == "" {
+		return nil, fmt.Errorf("%s has no Manifest-Version", jarManifest)
+	}
+	if a, ok := attrs["CI-Application"]; ok && a != application {
+		return nil, fmt.Errorf("%s names application %s, %s names %s", jarManifest, a, darManifest, application)
+	}
+	if v, ok := attrs["CI-Version"]; ok && v != version {
+		return nil, fmt.Errorf("%s names version %s, %s names %s", jarManifest, v, darManifest, version)
+	}
+
+	return b, nil
+}
+
+// manifestLines returns the lines of a MANIFEST.MF with continuation lines joined
+// jar wraps values at 72 bytes and starts every continuation line with a single space
+func manifestLines(b []byte) []string {
+	var lines []string
+
+	s := bufio.NewScanner(strings.NewReader(string(b)))
+	for s.Scan() {
+		l := s.Text()
+		if strings.HasPrefix(l, " ") && len(lines) != 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+// writeDar zips dir into file, with the MANIFEST.MF as first entry like jar does
+// version control directories and earlier built dars are left out
+func writeDar(dir, file string, mf []byte) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	w, err := zw.Create(jarManifest)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mf); err != nil {
+		return err
+	}
+
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		// skip the root, the manifest we already wrote and the dar itself when it is written inside dir
+		if name == "." || name == jarManifest {
+			return nil
+		}
+		if a, _ := filepath.Abs(p); a == abs {
+			return nil
+		}
+
+		if fi.IsDir() && vcsDirs[fi.Name()] {
+			return filepath.SkipDir
+		}
+		if !fi.IsDir() && strings.EqualFold(filepath.Ext(name), ".dar") {
+			return nil
+		}
+
+		if fi.IsDir() {
+			_, err := zw.Create(name + "/")
+			return err
+		}
+
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,216 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var repositoryDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "delete one or more cis from the repository",
+	Long:  "usage: delete <id>... [--recursive] [--dry-run] [--force]\ncis with children are only deleted with --recursive, cis with deployed applications only with --force",
+	Run:   DeleteCI,
+}
+
+var recursiveBool bool
+var dryRunBool bool
+var forceBool bool
+
+func init() {
+	repositoryDeleteCmd.Flags().BoolVarP(&recursiveBool, "recursive", "r", false, "also delete all children of the given cis")
+	repositoryDeleteCmd.Flags().BoolVar(&dryRunBool, "dry-run", false, "only list what would be deleted")
+	repositoryDeleteCmd.Flags().BoolVarP(&forceBool, "force", "f", false, "delete even when applications are still deployed")
+
+	repositoryCmd.AddCommand(repositoryDeleteCmd)
+}
+
+func DeleteCI(cmd *cobra.Command, args []string) {
+	var doomed []string
+
+	if len(args) == 0 {
+		jww.FATAL.Printf("%s: requires at least one argument", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	for _, i := range args {
+		children, err := queryCIs(api, url.Values{"ancestor": {i}, "resultsPerPage": {"-1"}})
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error retrieving the children of %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+
+		if len(children) != 0 && !recursiveBool {
+			jww.FATAL.Printf("%s: %s has %d children, use --recursive to delete them too", cmd.CommandPath(), i, len(children))
+			os.Exit(1)
+		}
+
+		doomed = append(doomed, i)
+		for _, c := range children {
+			doomed = append(doomed, c.ID)
+		}
+	}
+
+	if !forceBool {
+		deployed, err := deployedApplicationsOn(api, args)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error looking for deployed applications: %s", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+		if len(deployed) != 0 {
+			for _, d := range deployed {
+				jww.ERROR.Println(d)
+			}
+			jww.FATAL.Printf("%s: applications are still deployed, undeploy them first or use --force", cmd.CommandPath())
+			os.Exit(1)
+		}
+	}
+
+	if dryRunBool || recursiveBool {
+		for _, d := range doomed {
+			fmt.Println(d)
+		}
+	}
+
+	if dryRunBool {
+		return
+	}
+
+	// deleting a ci removes its children as well, so only the given ids are sent
+	err := api.do(http.MethodPost, "repository/cis/delete", nil, args, nil)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error deleting %s: %s", cmd.CommandPath(), strings.Join(args, ", "), err)
+		os.Exit(1)
+	}
+}
+
+// deployedApplicationsOn returns the deployed applications that would be affected by deleting the given ids
+// for environments those are the deployed applications below it, for infrastructure the ones with deployeds on its containers
+func deployedApplicationsOn(api *apiClient, ids []string) ([]string, error) {
+	var deployed []string
+	var infrastructure []string
+
+	for _, i := range ids {
+		switch {
+		case strings.HasPrefix(i, "Environments/"):
+			refs, err := queryCIs(api, url.Values{"type": {"udm.DeployedApplication"}, "ancestor": {i}, "resultsPerPage": {"-1"}})
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range refs {
+				deployed = append(deployed, r.ID)
+			}
+		case strings.HasPrefix(i, "Infrastructure/"):
+			infrastructure = append(infrastructure, i)
+		}
+	}
+
+	if len(infrastructure) == 0 {
+		return deployed, nil
+	}
+
+	// deployeds can only be on containers that are member of an environment,
+	// so only the environments using the infrastructure have to be searched
+	envs, err := environmentsUsing(api, infrastructure)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range envs {
+		refs, err := queryCIs(api, url.Values{"type": {"udm.Deployed"}, "ancestor": {e}, "resultsPerPage": {"-1"}})
+		if err != nil {
+			return nil, err
+		}
+
+		var rids []string
+		for _, r := range refs {
+			rids = append(rids, r.ID)
+		}
+
+		cis, err := readCIs(api, rids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ci := range cis {
+			c, err := ciReference(ci["container"])
+			if err != nil {
+				continue
+			}
+			if below(c, infrastructure) {
+				deployed = append(deployed, fmt.Sprintf("%v on %s", ci["id"], c))
+			}
+		}
+	}
+
+	return deployed, nil
+}
+
+// environmentsUsing returns the environments with a member on or below one of the infrastructure ids
+func environmentsUsing(api *apiClient, infrastructure []string) ([]string, error) {
+	var used []string
+
+	refs, err := queryCIs(api, url.Values{"type": {"udm.Environment"}, "resultsPerPage": {"-1"}})
+	if err != nil || len(refs) == 0 {
+		return nil, err
+	}
+
+	var eids []string
+	for _, r := range refs {
+		eids = append(eids, r.ID)
+	}
+
+	envs, err := readCIs(api, eids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range envs {
+		members, _ := e["members"].([]interface{})
+		for _, m := range members {
+			if id, err := ciReference(m); err == nil && below(id, infrastructure) {
+				used = append(used, fmt.Sprint(e["id"]))
+				break
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// below tells if id is one of the given ids or one of their descendants
+func below(id string, ids []string) bool {
+	for _, i := range ids {
+		if id == i || strings.HasPrefix(id, i+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// deployment is the deployment specification as handed out by xldeploy
+// it is passed back and forth between the prepare, validate and create calls so we keep it as is
+type deployment map[string]interface{}
+
+// deployCmd represents the deploy command
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "deploy an application version to an environment",
+	Long:  "usage: deploy <application version id> <environment id>\nperforms an initial or update deployment depending on what is already deployed",
+	Run:   deploy,
+}
+
+// previewBlock is a block of the plan xldeploy would execute for a deployment
+type previewBlock struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Blocks      []previewBlock `json:"blocks,omitempty"`
+	Steps       []previewStep  `json:"steps,omitempty"`
+}
+
+// previewStep is a single planned step
+type previewStep struct {
+	Order       int    `json:"order"`
+	Description string `json:"description"`
+}
+
+// taskPreview is the answer of xldeploy on a preview request
+type taskPreview struct {
+	ID    string       `json:"id"`
+	Block previewBlock `json:"block"`
+}
+
+var waitBool bool
+var previewBool bool
+var jsonBool bool
+
+func init() {
+	deployCmd.Flags().BoolVarP(&waitBool, "wait", "w", false, "wait for the deployment task to finish")
+	deployCmd.Flags().DurationVar(&pollInterval, "interval", 2*time.Second, "time between two polls of the task state")
+	deployCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "maximum time to wait for the task, 0 waits forever")
+	deployCmd.Flags().BoolVar(&previewBool, "preview", false, "only show the steps the deployment would execute, no task is created")
+	deployCmd.Flags().BoolVar(&jsonBool, "json", false, "render the preview as json instead of a tree")
+
+	RootCmd.AddCommand(deployCmd)
+}
+
+func deploy(cmd *cobra.Command, args []string) {
+
+	if len(args) != 2 {
+		jww.FATAL.Printf("%s: requires an application version id and an environment id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	version := args[0]
+	environment := args[1]
+
+	api := getAPIClient()
+
+	dep, err := prepareDeployment(api, version, environment)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error preparing the deployment of %s to %s: %s", cmd.CommandPath(), version, environment, err)
+		os.Exit(1)
+	}
+
+	dep, err = generateDeployeds(api, dep)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error generating deployeds: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	dep, err = validateDeployment(api, dep)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error validating the deployment: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	if previewBool {
+		previewDeployment(cmd, api, dep)
+		return
+	}
+
+	runDeploymentTask(cmd, api, dep)
+}
+
+// prepareDeployment prepares an update when the application is already on the environment
+// and an initial deployment otherwise
+func prepareDeployment(api *apiClient, version, environment string) (deployment, error) {
+	var dep deployment
+	var exists bool
+
+	// the deployed application is named after the application, which is the parent of the version
+	deployedApplication := path.Join(environment, path.Base(path.Dir(version)))
+
+	err := api.do(http.MethodGet, "deployment/exists", url.Values{"application": {deployedApplication}}, nil, &exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		jww.INFO.Printf("%s exists, preparing update deployment", deployedApplication)
+		q := url.Values{"version": {version}, "deployedApplication": {deployedApplication}}
+		err = api.do(http.MethodGet, "deployment/prepare/update", q, nil, &dep)
+	} else {
+		jww.INFO.Printf("%s does not exist, preparing initial deployment", deployedApplication)
+		q := url.Values{"version": {version}, "environment": {environment}}
+		err = api.do(http.MethodGet, "deployment/prepare/initial", q, nil, &dep)
+	}
+
+	return dep, err
+}
+
+// generateDeployeds lets xldeploy map all deployables in the deployment to the containers of the environment
+func generateDeployeds(api *apiClient, dep deployment) (deployment, error) {
+	var out deployment
+	err := api.do(http.MethodPost, "deployment/prepare/deployeds", nil, dep, &out)
+	return out, err
+}
+
+// validateDeployment has the deployment validated by xldeploy and returns an error listing all validation messages
+func validateDeployment(api *apiClient, dep deployment) (deployment, error) {
+	var out deployment
+
+	err := api.do(http.MethodPost, "deployment/validate", nil, dep, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	if msgs := validationMessages(out); len(msgs) != 0 {
+		for _, m := range msgs {
+			jww.ERROR.Println(m)
+		}
+		return nil, fmt.Errorf("deployment has %d validation error(s)", len(msgs))
+	}
+
+	return out, nil
+}
+
+// validationMessages collects the validation messages xldeploy attached to the deployeds of a deployment
+func validationMessages(dep deployment) []string {
+	var msgs []string
+
+	deployeds, _ := dep["deployeds"].([]interface{})
+	for _, d := range deployeds {
+		dm, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vms, _ := dm["validation-messages"].([]interface{})
+		for _, vm := range vms {
+			m, ok := vm.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			msgs = append(msgs, fmt.Sprintf("%v: %v: %v", m["ci"], m["property"], m["message"]))
+		}
+	}
+
+	return msgs
+}
+
+// previewDeployment asks xldeploy for the plan of a deployment and renders it as json or as a tree
+func previewDeployment(cmd *cobra.Command, api *apiClient, dep deployment) {
+	var p taskPreview
+
+	err := api.do(http.MethodPost, "deployment/previewblock", nil, dep, &p)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error previewing the deployment: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	err = fillPreviewSteps(api, p.ID, &p.Block)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error retrieving the preview steps: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	if jsonBool {
+		RenderJSON(p.Block)
+		return
+	}
+
+	printPreviewBlock(os.Stdout, p.Block, "")
+}
+
+// fillPreviewSteps retrieves the steps of every step block in the preview
+func fillPreviewSteps(api *apiClient, id string, b *previewBlock) error {
+	if len(b.Blocks) == 0 {
+		var sb previewBlock
+		err := api.do(http.MethodGet, "deployment/previewblock/"+id+"/"+b.ID, nil, nil, &sb)
+		b.Steps = sb.Steps
+		return err
+	}
+
+	for i := range b.Blocks {
+		if err := fillPreviewSteps(api, id, &b.Blocks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printPreviewBlock writes a block and everything below it as an indented tree
+func printPreviewBlock(out io.Writer, b previewBlock, indent string) {
+	fmt.Fprintf(out, "%s%s\n", indent, b.Description)
+
+	for _, c := range b.Blocks {
+		printPreviewBlock(out, c, indent+"  ")
+	}
+
+	for _, s := range b.Steps {
+		fmt.Fprintf(out, "%s  %3d  %s\n", indent, s.Order, s.Description)
+	}
+}
+
+// runDeploymentTask creates the task for a deployment and runs it
+func runDeploymentTask(cmd *cobra.Command, api *apiClient, dep deployment) {
+	var id string
+
+	err := api.do(http.MethodPost, "deployment", nil, dep, &id)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error creating the deployment task: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	runTask(cmd, api, id)
+}
+
+// runTask starts a task and when requested waits for it to finish
+// the resulting task is rendered as json
+func runTask(cmd *cobra.Command, api *apiClient, id string) {
+	err := startTask(api, id)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error starting task %s: %s", cmd.CommandPath(), id, err)
+		os.Exit(1)
+	}
+
+	var t task
+	if waitBool {
+		t, err = waitForTask(api, id)
+	} else {
+		t, err = getTask(api, id)
+	}
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error retrieving task %s: %s", cmd.CommandPath(), id, err)
+		os.Exit(1)
+	}
+
+	RenderJSON(t)
+
+	if waitBool && t.State != "DONE" {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// passwordMask replaces password values in exported files
+const passwordMask = "********"
+
+var repositoryExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export a ci and everything below it to files",
+	Long:  "usage: export <root id> --dir <path> [--format json|yaml] [--passwords mask|omit]\nwrites one file per ci, in directories following the repository path",
+	Run:   ExportCI,
+}
+
+var dir string
+var format string
+var passwords string
+
+func init() {
+	repositoryExportCmd.Flags().StringVar(&dir, "dir", "", "directory to export to")
+	repositoryExportCmd.Flags().StringVar(&format, "format", "json", "file format, json or yaml")
+	repositoryExportCmd.Flags().StringVar(&passwords, "passwords", "mask", "mask or omit password properties")
+
+	repositoryCmd.AddCommand(repositoryExportCmd)
+}
+
+func ExportCI(cmd *cobra.Command, args []string) {
+	if len(args) != 1 || dir == "" {
+		jww.FATAL.Printf("%s: requires a root id and --dir", cmd.CommandPath())
+		os.Exit(1)
+	}
+	if format != "json" && format != "yaml" {
+		jww.FATAL.Printf("%s: unknown format %s", cmd.CommandPath(), format)
+		os.Exit(1)
+	}
+	if passwords != "mask" && passwords != "omit" {
+		jww.FATAL.Printf("%s: --passwords is either mask or omit", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+	lookup := liveTypeLookup()
+
+	cis, err := readSubtree(api, args[0])
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	for _, ci := range cis {
+		i, _ := ci["id"].(string)
+
+		if err := hidePasswords(ci, lookup, passwords == "omit"); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata for %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+
+		f := filepath.Join(dir, filepath.FromSlash(i)) + "." + format
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error creating %s: %s", cmd.CommandPath(), filepath.Dir(f), err)
+			os.Exit(1)
+		}
+
+		doc := newCIDocument(ci)
+		if format == "yaml" {
+			WriteYAMLToFile(doc.yaml(), f)
+		} else {
+			WriteJSONToFile(doc, f)
+		}
+
+		fmt.Println(f)
+	}
+}
+
+// hidePasswords masks or omits the password properties of a ci and of the cis embedded in it
+// embedded cis are looked up by their own type
+func hidePasswords(ci map[string]interface{}, lookup typeLookup, omit bool) error {
+	t, _ := ci["type"].(string)
+	tt, err := lookup(t)
+	if err != nil {
+		return fmt.Errorf("type %s: %s", t, err)
+	}
+
+	for _, p := range tt.Properties {
+		v, ok := ci[p.Name]
+		if !ok {
+			continue
+		}
+
+		if p.Password {
+			if omit {
+				delete(ci, p.Name)
+			} else {
+				ci[p.Name] = passwordMask
+			}
+			continue
+		}
+
+		if !p.AsContainment {
+			continue
+		}
+		switch e := v.(type) {
+		case map[string]interface{}:
+			if err := hidePasswords(e, lookup, omit); err != nil {
+				return err
+			}
+		case []interface{}:
+			for _, i := range e {
+				if m, ok := i.(map[string]interface{}); ok {
+					if err := hidePasswords(m, lookup, omit); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ciDocument is a ci with its keys in a stable order, id and type first and the properties sorted by name
+// so exported files only change when the ci does
+type ciDocument struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newCIDocument(ci map[string]interface{}) ciDocument {
+	d := ciDocument{keys: []string{"id", "type"}, values: ci}
+
+	var props []string
+	for k := range ci {
+		if k == "id" || k == "type" || strings.HasPrefix(k, "$") {
+			continue
+		}
+		props = append(props, k)
+	}
+	sort.Strings(props)
+
+	d.keys = append(d.keys, props...)
+	return d
+}
+
+func (d ciDocument) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteString("{")
+	for n, k := range d.keys {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(d.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteString(":")
+		b.Write(vb)
+	}
+	b.WriteString("}")
+
+	return b.Bytes(), nil
+}
+
+func (d ciDocument) yaml() yaml.MapSlice {
+	var ms yaml.MapSlice
+	for _, k := range d.keys {
+		ms = append(ms, yaml.MapItem{Key: k, Value: d.values[k]})
+	}
+	return ms
+}
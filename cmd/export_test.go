@@ -0,0 +1,79 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/viveleroy/goxldeploy"
+)
+
+func TestHidePasswords(t *testing.T) {
+	lookup := testTypeLookup(
+		goxldeploy.Type{Type: "test.Server", Properties: []goxldeploy.PropertyDescriptor{
+			{Name: "password", Kind: "STRING", Password: true},
+			{Name: "user", Kind: "STRING"},
+			{Name: "checks", Kind: "SET_OF_CI", AsContainment: true},
+		}},
+		goxldeploy.Type{Type: "test.Check", Properties: []goxldeploy.PropertyDescriptor{
+			{Name: "token", Kind: "STRING", Password: true},
+		}},
+	)
+
+	ci := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id": "Infrastructure/a", "type": "test.Server", "password": "secret", "user": "u",
+			"checks": []interface{}{
+				map[string]interface{}{"id": "Infrastructure/a/check", "type": "test.Check", "token": "secret"},
+			},
+		}
+	}
+
+	tests := []struct {
+		omit bool
+		want map[string]interface{}
+	}{
+		{false, map[string]interface{}{
+			"id": "Infrastructure/a", "type": "test.Server", "password": passwordMask, "user": "u",
+			"checks": []interface{}{
+				map[string]interface{}{"id": "Infrastructure/a/check", "type": "test.Check", "token": passwordMask},
+			},
+		}},
+		{true, map[string]interface{}{
+			"id": "Infrastructure/a", "type": "test.Server", "user": "u",
+			"checks": []interface{}{
+				map[string]interface{}{"id": "Infrastructure/a/check", "type": "test.Check"},
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		got := ci()
+		if err := hidePasswords(got, lookup, tt.omit); err != nil {
+			t.Errorf("hidePasswords(omit %v) returned error %s", tt.omit, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hidePasswords(omit %v) = %v, want %v", tt.omit, got, tt.want)
+		}
+	}
+}
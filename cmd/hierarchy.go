@@ -0,0 +1,177 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+var metaHierarchyCommand = &cobra.Command{
+	Use:   "hierarchy",
+	Short: "Display supertypes, interfaces and subtypes of a type",
+	Long:  "usage: hierarchy <type> [--concrete]\nprints the ancestor chain and the descendant tree of a type or interface, virtual types are marked as such\nwith --concrete only the names of the non virtual descendants are listed",
+	Run:   getTypeHierarchy,
+}
+
+var concreteBool bool
+
+func init() {
+	metaHierarchyCommand.Flags().BoolVar(&concreteBool, "concrete", false, "only list the concrete (non virtual) descendants")
+
+	metaCmd.AddCommand(metaHierarchyCommand)
+}
+
+func getTypeHierarchy(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a type", cmd.CommandPath())
+		os.Exit(1)
+	}
+	name := args[0]
+
+	xld := GetClient()
+
+	tl, err := xld.Metadata.GetTypeList()
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	types := make(map[string]goxldeploy.Type)
+	for _, t := range tl {
+		types[t.Type] = t
+	}
+
+	// interfaces are not listed as types, they only show up in the interfaces of their implementations
+	t, ok := types[name]
+	if !ok && !isInterface(tl, name) {
+		jww.FATAL.Printf("%s: unknown type %s", cmd.CommandPath(), name)
+		os.Exit(1)
+	}
+
+	children := subTypes(tl, types, name)
+
+	if concreteBool {
+		for _, c := range descendants(children, name) {
+			if !types[c].Virtual {
+				fmt.Println(c)
+			}
+		}
+		return
+	}
+
+	if !ok {
+		fmt.Printf("%s (interface)\n", name)
+	} else {
+		// supertypes are listed nearest first, print them from the root down
+		indent := ""
+		for i := len(t.SuperTypes) - 1; i >= 0; i-- {
+			fmt.Printf("%s%s%s\n", indent, t.SuperTypes[i], typeMarker(types, t.SuperTypes[i]))
+			indent += "  "
+		}
+		fmt.Printf("%s%s%s\n", indent, name, typeMarker(types, name))
+
+		if len(t.Interfaces) != 0 {
+			fmt.Printf("interfaces: %s\n", strings.Join(t.Interfaces, ", "))
+		}
+	}
+
+	fmt.Printf("\n%s\n", name)
+	count := printTypeTree(os.Stdout, types, children, name, "")
+	fmt.Printf("\n%d subtype(s)\n", count)
+}
+
+// subTypes maps every type in the hierarchy below name to its direct children
+// a type hangs below its direct supertype when that supertype is part of the hierarchy, otherwise directly below name
+func subTypes(tl goxldeploy.TypeList, types map[string]goxldeploy.Type, name string) map[string][]string {
+	children := make(map[string][]string)
+
+	for _, t := range tl {
+		if t.Type == name || !isA(t, name) {
+			continue
+		}
+
+		parent := name
+		if len(t.SuperTypes) != 0 {
+			if s, ok := types[t.SuperTypes[0]]; ok && isA(s, name) {
+				parent = s.Type
+			}
+		}
+		children[parent] = append(children[parent], t.Type)
+	}
+
+	for k := range children {
+		sort.Strings(children[k])
+	}
+
+	return children
+}
+
+// descendants returns all types below name, sorted
+func descendants(children map[string][]string, name string) []string {
+	var d []string
+	for _, c := range children[name] {
+		d = append(d, c)
+		d = append(d, descendants(children, c)...)
+	}
+	sort.Strings(d)
+	return d
+}
+
+// printTypeTree writes the subtypes of name as a tree and returns the number of types printed
+func printTypeTree(out io.Writer, types map[string]goxldeploy.Type, children map[string][]string, name, prefix string) int {
+	count := 0
+
+	for i, c := range children[name] {
+		branch, indent := "├── ", "│   "
+		if i == len(children[name])-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		fmt.Fprintf(out, "%s%s%s%s\n", prefix, branch, c, typeMarker(types, c))
+		count += 1 + printTypeTree(out, types, children, c, prefix+indent)
+	}
+
+	return count
+}
+
+func typeMarker(types map[string]goxldeploy.Type, name string) string {
+	if t, ok := types[name]; ok && t.Virtual {
+		return " (virtual)"
+	}
+	return ""
+}
+
+func isInterface(tl goxldeploy.TypeList, name string) bool {
+	for _, t := range tl {
+		if containsString(t.Interfaces, name) {
+			return true
+		}
+	}
+	return false
+}
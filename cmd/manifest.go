@@ -0,0 +1,405 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+var packageLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "validate a deployit-manifest.xml against the type metadata",
+	Long:  "usage: lint <deployit-manifest.xml> [--metadata <snapshot.json>]\nchecks types, properties, property kinds and placeholders, a snapshot made with metadata type --long --out allows linting without a server",
+	Run:   lintManifestCmd,
+	// only talk to xldeploy when no metadata snapshot is used
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if metadataFile == "" {
+			preVerifyConnection(cmd, args)
+		}
+	},
+}
+
+var metadataFile string
+
+func init() {
+	packageLintCmd.Flags().StringVar(&metadataFile, "metadata", "", "metadata snapshot to lint against instead of the server")
+	packageLintCmd.Flags().BoolVar(&jsonBool, "json", false, "render the issues as json")
+
+	packageCmd.AddCommand(packageLintCmd)
+}
+
+func lintManifestCmd(cmd *cobra.Command, args []string) {
+	var lookup typeLookup
+	var err error
+
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a manifest file", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	m, err := readManifest(args[0])
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	if metadataFile != "" {
+		lookup, err = snapshotTypeLookup(metadataFile)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error reading metadata snapshot %s: %s", cmd.CommandPath(), metadataFile, err)
+			os.Exit(1)
+		}
+	} else {
+		lookup = liveTypeLookup()
+	}
+
+	issues := lintManifest(m, lookup)
+
+	if jsonBool {
+		RenderJSON(issues)
+	} else {
+		for _, i := range issues {
+			fmt.Println(i)
+		}
+	}
+
+	if len(issues) != 0 {
+		os.Exit(1)
+	}
+}
+
+// manifestNode is a generic element of a deployit-manifest.xml
+// the element names are ci types and property names, so they can not be mapped on fixed structs
+type manifestNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr     `xml:",any,attr"`
+	Nodes   []manifestNode `xml:",any"`
+	Text    string         `xml:",chardata"`
+}
+
+// manifestIssue is a single problem found in a manifest
+type manifestIssue struct {
+	CI       string `json:"ci"`
+	Property string `json:"property,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i manifestIssue) String() string {
+	if i.Property == "" {
+		return fmt.Sprintf("%s: %s", i.CI, i.Message)
+	}
+	return fmt.Sprintf("%s.%s: %s", i.CI, i.Property, i.Message)
+}
+
+// typeLookup resolves a ci type to its metadata
+type typeLookup func(string) (goxldeploy.Type, error)
+
+// readManifest parses a deployit-manifest.xml
+func readManifest(file string) (manifestNode, error) {
+	var m manifestNode
+
+	f, err := os.Open(file)
+	if err != nil {
+		return m, err
+	}
+	defer f.Close()
+
+	err = xml.NewDecoder(f).Decode(&m)
+	return m, err
+}
+
+func (n manifestNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n manifestNode) child(name string) *manifestNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// deployables returns the ci elements of the deployables section
+func (n manifestNode) deployables() []manifestNode {
+	if d := n.child("deployables"); d != nil {
+		return d.Nodes
+	}
+	return nil
+}
+
+// lintManifest checks the deployment package and every ci in it against the type metadata
+func lintManifest(m manifestNode, lookup typeLookup) []manifestIssue {
+	var issues []manifestIssue
+
+	application := m.attr("application")
+
+	// the package itself is linted without its deployables and without required properties, xldeploy fills those on import
+	pkg := m
+	pkg.Nodes = nil
+	for _, c := range m.Nodes {
+		if c.XMLName.Local != "deployables" {
+			pkg.Nodes = append(pkg.Nodes, c)
+		}
+	}
+	issues = append(issues, lintCI(pkg, application, lookup, false)...)
+
+	for _, d := range m.deployables() {
+		issues = append(issues, lintCI(d, application+"/"+d.attr("name"), lookup, true)...)
+	}
+
+	return issues
+}
+
+// lintCI checks the type, properties and placeholders of a single ci element and of the cis embedded in it
+func lintCI(n manifestNode, name string, lookup typeLookup, required bool) []manifestIssue {
+	var issues []manifestIssue
+
+	for _, a := range n.Attrs {
+		if err := checkPlaceholders(a.Value); err != nil {
+			issues = append(issues, manifestIssue{CI: name, Property: a.Name.Local, Message: err.Error()})
+		}
+	}
+
+	t, err := lookup(n.XMLName.Local)
+	if err != nil {
+		return append(issues, manifestIssue{CI: name, Message: fmt.Sprintf("unknown type %s: %s", n.XMLName.Local, err)})
+	}
+
+	props := make(map[string]goxldeploy.PropertyDescriptor)
+	for _, p := range t.Properties {
+		props[p.Name] = p
+	}
+
+	for _, c := range n.Nodes {
+		p, ok := props[c.XMLName.Local]
+		if !ok {
+			issues = append(issues, manifestIssue{CI: name, Property: c.XMLName.Local, Message: fmt.Sprintf("unknown property for type %s", t.Type)})
+			continue
+		}
+		issues = append(issues, lintProperty(c, p, name, lookup)...)
+	}
+
+	if required {
+		for _, p := range t.Properties {
+			if p.Required && p.Default == nil && n.child(p.Name) == nil {
+				issues = append(issues, manifestIssue{CI: name, Property: p.Name, Message: "required property is not set"})
+			}
+		}
+	}
+
+	return issues
+}
+
+// lintProperty checks that the value of a property element matches the kind the metadata declares
+func lintProperty(n manifestNode, p goxldeploy.PropertyDescriptor, ci string, lookup typeLookup) []manifestIssue {
+	var issues []manifestIssue
+
+	issue := func(format string, a ...interface{}) {
+		issues = append(issues, manifestIssue{CI: ci, Property: p.Name, Message: fmt.Sprintf(format, a...)})
+	}
+
+	text := strings.TrimSpace(n.Text)
+	if err := checkPlaceholders(text); err != nil {
+		issue("%s", err)
+	}
+
+	kind := strings.ToUpper(p.Kind)
+
+	switch kind {
+	case "BOOLEAN", "INTEGER", "ENUM", "STRING", "DATE":
+		if len(n.Nodes) != 0 {
+			issue("expected a %s value, got nested elements", strings.ToLower(kind))
+			break
+		}
+		if text == "" || hasPlaceholder(text) {
+			break
+		}
+		switch kind {
+		case "BOOLEAN":
+			if _, err := strconv.ParseBool(text); err != nil {
+				issue("expected a boolean, got %q", text)
+			}
+		case "INTEGER":
+			if _, err := strconv.Atoi(text); err != nil {
+				issue("expected an integer, got %q", text)
+			}
+		case "ENUM":
+			if !containsString(p.EnumValues, text) {
+				issue("%q is not one of %s", text, strings.Join(p.EnumValues, ", "))
+			}
+		}
+
+	case "SET_OF_STRING", "LIST_OF_STRING":
+		if text != "" {
+			issue("expected %s as <value> elements, got %q", strings.ToLower(kind), text)
+		}
+		for _, c := range n.Nodes {
+			if c.XMLName.Local != "value" {
+				issue("expected <value> elements, got <%s>", c.XMLName.Local)
+				continue
+			}
+			if err := checkPlaceholders(c.Text); err != nil {
+				issue("%s", err)
+			}
+		}
+
+	case "MAP_STRING_STRING":
+		if text != "" {
+			issue("expected map_string_string as <entry key=\"\"> elements, got %q", text)
+		}
+		for _, c := range n.Nodes {
+			if c.XMLName.Local != "entry" || c.attr("key") == "" {
+				issue("expected <entry key=\"\"> elements, got <%s>", c.XMLName.Local)
+				continue
+			}
+			if err := checkPlaceholders(c.Text); err != nil {
+				issue("%s", err)
+			}
+		}
+
+	case "CI":
+		if len(n.Nodes) != 0 {
+			issue("expected a ci reference, got nested elements")
+		}
+
+	case "SET_OF_CI", "LIST_OF_CI":
+		if text != "" {
+			issue("expected %s as <ci ref=\"\"> elements, got %q", strings.ToLower(kind), text)
+		}
+		for _, c := range n.Nodes {
+			if c.XMLName.Local == "ci" {
+				if c.attr("ref") == "" {
+					issue("<ci> element without ref")
+				}
+				continue
+			}
+			// anything else is an embedded ci, named after its type
+			if !p.AsContainment {
+				issue("%s can not contain embedded cis, use <ci ref=\"\">", strings.ToLower(kind))
+				continue
+			}
+			issues = append(issues, lintCI(c, ci+"/"+c.attr("name"), lookup, true)...)
+		}
+	}
+
+	return issues
+}
+
+// checkPlaceholders validates the {{placeholder}} syntax in a value
+func checkPlaceholders(s string) error {
+	for {
+		i := strings.Index(s, "{{")
+		j := strings.Index(s, "}}")
+
+		switch {
+		case i < 0 && j < 0:
+			return nil
+		case i < 0 || (j >= 0 && j < i):
+			return fmt.Errorf("placeholder closed without being opened in %q", s)
+		case j < 0:
+			return fmt.Errorf("placeholder not closed in %q", s)
+		}
+
+		name := s[i+2 : j]
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("empty placeholder in %q", s)
+		}
+		if strings.Contains(name, "{{") {
+			return fmt.Errorf("nested placeholder in %q", s)
+		}
+
+		s = s[j+2:]
+	}
+}
+
+func hasPlaceholder(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotTypeLookup resolves types from a metadata snapshot as written by metadata type --long --out
+func snapshotTypeLookup(file string) (typeLookup, error) {
+	var tl goxldeploy.TypeList
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tl); err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]goxldeploy.Type)
+	for _, t := range tl {
+		types[t.Type] = t
+	}
+
+	return func(name string) (goxldeploy.Type, error) {
+		t, ok := types[name]
+		if !ok {
+			return t, fmt.Errorf("not in metadata snapshot %s", file)
+		}
+		return t, nil
+	}, nil
+}
+
+// liveTypeLookup resolves types against the connected xldeploy, asking for every type only once
+func liveTypeLookup() typeLookup {
+	xld := GetClient()
+	cache := make(map[string]goxldeploy.Type)
+
+	return func(name string) (goxldeploy.Type, error) {
+		if t, ok := cache[name]; ok {
+			return t, nil
+		}
+		t, err := xld.Metadata.GetType(name)
+		if err != nil {
+			return t, err
+		}
+		cache[name] = t
+		return t, nil
+	}
+}
@@ -21,12 +21,16 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/viveleroy/goxldeploy"
+	yaml "gopkg.in/yaml.v2"
 )
 
 //typeShort is used in provicing a non verbose display of a type or a typelist
@@ -51,7 +55,7 @@ var metaTypeCommand = &cobra.Command{
 var metaTemplateCommand = &cobra.Command{
 	Use:   "template",
 	Short: "Display's template for type creation",
-	Long:  "fetches metadata from xldeploy for a single type and returns a json template that can be used for the creation of said type",
+	Long:  "fetches metadata from xldeploy for a single type and returns a json or yaml template that can be used for the creation of said type with repository create --in",
 	Run:   getTypeTemplate,
 }
 
@@ -77,6 +81,7 @@ func init() {
 	// add flags to the various previously defined commands
 	metaTypeCommand.Flags().BoolVarP(&longBool, "long", "l", false, "print long listing instead of condensed output")
 	metaTemplateCommand.Flags().BoolVarP(&optionalBool, "optional", "o", false, "include optional parameters in template")
+	metaTemplateCommand.Flags().StringVar(&format, "format", "json", "template format, json or yaml (with the property descriptions as comments)")
 
 	// add the commands to da mothership
 	metaCmd.AddCommand(metaTypeCommand)
@@ -94,7 +99,11 @@ func init() {
 //Gets a list of
 func getTypeTemplate(cmd *cobra.Command, args []string) {
 	var tl goxldeploy.TypeList
-	var tmpl []map[string]interface{}
+
+	if format != "json" && format != "yaml" {
+		jww.FATAL.Printf("%s: unknown format %s", cmd.CommandPath(), format)
+		os.Exit(1)
+	}
 
 	xld := GetClient()
 
@@ -112,33 +121,60 @@ func getTypeTemplate(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// loop over the typelist (tl) and create a template type map[string]interface{} for each element
-	for _, t := range tl {
-		templ := make(map[string]interface{})
+	// loop over the typelist (tl) and create a template for each element
+	// the properties keep the metadata order and get a value that fits their kind
+	// properties without a usable value, like ci references, only show up commented out in the yaml
+	var tmpl []ciDocument
+	var comments []map[string]string
 
-		templ["name"] = ""
-		templ["type"] = t.Type
+	for _, t := range tl {
+		templ := ciDocument{keys: []string{"id", "type"}, values: map[string]interface{}{"id": "", "type": t.Type}}
+		comment := make(map[string]string)
 
 		for _, p := range t.Properties {
-			if p.Required == true {
-				if p.Default == nil {
-					templ[p.Name] = "required"
-				} else {
-					templ[p.Name] = p.Default
-				}
-			} else {
-				if optionalBool == true {
-					templ[p.Name] = "very optional"
+			// the containing ci follows from the id
+			if p.AsContainment && strings.EqualFold(p.Kind, "CI") {
+				continue
+			}
+			if p.Required || optionalBool {
+				templ.keys = append(templ.keys, p.Name)
+				if v := templateValue(p); v != nil {
+					templ.values[p.Name] = v
 				}
+				comment[p.Name] = templateComment(p)
 			}
 		}
 		tmpl = append(tmpl, templ)
+		comments = append(comments, comment)
+	}
+
+	if format == "yaml" {
+		var docs []string
+		for i, t := range tmpl {
+			docs = append(docs, commentedYAML(t, comments[i]))
+		}
+		o := strings.Join(docs, "---\n")
+
+		if outputFile != "" {
+			if err := ioutil.WriteFile(outputFile, []byte(o), 0644); err != nil {
+				panic(err)
+			}
+			os.Exit(0)
+		}
+
+		fmt.Print(o)
+		os.Exit(0)
 	}
 
 	// handle the output
 	// if only one template was given then we do not want to bother our esteemd users with a slice representation
+	// the json template has to be accepted as is, so properties without value are left out
 	var o interface{}
 
+	for i := range tmpl {
+		tmpl[i] = filledTemplate(tmpl[i])
+	}
+
 	if len(tmpl) == 1 {
 		o = tmpl[0]
 	} else {
@@ -155,6 +191,91 @@ func getTypeTemplate(cmd *cobra.Command, args []string) {
 	os.Exit(0)
 }
 
+// templateValue returns the default of a property or else an empty value of its kind
+// enums get their first value, ci references have no sensible value and return nil
+func templateValue(p goxldeploy.PropertyDescriptor) interface{} {
+	if p.Default != nil {
+		if v, err := coerceValue(p, p.Default); err == nil {
+			return v
+		}
+	}
+
+	switch strings.ToUpper(p.Kind) {
+	case "BOOLEAN":
+		return false
+	case "INTEGER":
+		return 0
+	case "ENUM":
+		if len(p.EnumValues) != 0 {
+			return p.EnumValues[0]
+		}
+	case "CI":
+		return nil
+	case "SET_OF_STRING", "LIST_OF_STRING", "SET_OF_CI", "LIST_OF_CI":
+		return []string{}
+	case "MAP_STRING_STRING":
+		return map[string]string{}
+	}
+	return ""
+}
+
+// filledTemplate returns the template without the properties that have no value
+func filledTemplate(d ciDocument) ciDocument {
+	f := ciDocument{values: d.values}
+	for _, k := range d.keys {
+		if _, ok := d.values[k]; ok {
+			f.keys = append(f.keys, k)
+		}
+	}
+	return f
+}
+
+// templateComment describes a property for the yaml template
+func templateComment(p goxldeploy.PropertyDescriptor) string {
+	c := strings.ToLower(p.Kind)
+	if p.Required {
+		c += ", required"
+	}
+	if p.Password {
+		c += ", password"
+	}
+	if len(p.EnumValues) != 0 {
+		c += ", one of: " + strings.Join(p.EnumValues, ", ")
+	}
+	if p.ReferencedType != "" {
+		c += ", refers to " + p.ReferencedType
+	}
+	if p.Description != "" {
+		c = p.Description + "\n" + c
+	}
+	return c
+}
+
+// commentedYAML renders a template as yaml with the comments above the properties
+// properties without value are commented out, so the file is valid as it is
+func commentedYAML(d ciDocument, comments map[string]string) string {
+	var b bytes.Buffer
+
+	for _, k := range d.keys {
+		if c, ok := comments[k]; ok {
+			for _, l := range strings.Split(c, "\n") {
+				fmt.Fprintf(&b, "# %s\n", l)
+			}
+		}
+		if _, ok := d.values[k]; !ok {
+			fmt.Fprintf(&b, "# %s:\n", k)
+			continue
+		}
+		y, err := yaml.Marshal(yaml.MapSlice{{Key: k, Value: d.values[k]}})
+		if err != nil {
+			panic(err)
+		}
+		b.Write(y)
+	}
+
+	return b.String()
+}
+
 func getTypeMetadata(cmd *cobra.Command, args []string) {
 
 	//Lets declare an interface for output
@@ -209,6 +330,11 @@ func getTypeMetadata(cmd *cobra.Command, args []string) {
 			fmt.Printf("I don't know, ask stackoverflow.")
 		}
 	} else {
+		// the long listing written to file doubles as metadata snapshot for package lint
+		if outputFile != "" {
+			WriteJSONToFile(o, outputFile)
+			os.Exit(0)
+		}
 		RenderJSON(o)
 	}
 
@@ -0,0 +1,184 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+var repositoryRenameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "rename a ci",
+	Long:  "usage: rename <id> <new name>",
+	Run:   RenameCI,
+}
+
+var repositoryMoveCmd = &cobra.Command{
+	Use:   "move",
+	Short: "move a ci to another parent",
+	Long:  "usage: move <id> <new parent id>\nthe new parent has to be able to hold the type of the ci",
+	Run:   MoveCI,
+}
+
+func init() {
+	repositoryCmd.AddCommand(repositoryRenameCmd)
+	repositoryCmd.AddCommand(repositoryMoveCmd)
+}
+
+func RenameCI(cmd *cobra.Command, args []string) {
+	var ci map[string]interface{}
+
+	if len(args) != 2 {
+		jww.FATAL.Printf("%s: requires an id and a new name", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	if args[1] == "" || strings.Contains(args[1], "/") {
+		jww.FATAL.Printf("%s: %q is not a valid name", cmd.CommandPath(), args[1])
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	if err := checkFree(api, path.Join(path.Dir(args[0]), args[1])); err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	err := api.do(http.MethodPost, "repository/rename/"+args[0], url.Values{"newName": {args[1]}}, nil, &ci)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error renaming %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	RenderJSON(ci)
+}
+
+func MoveCI(cmd *cobra.Command, args []string) {
+	var ci map[string]interface{}
+
+	if len(args) != 2 {
+		jww.FATAL.Printf("%s: requires an id and a new parent id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	xld := GetClient()
+	api := getAPIClient()
+
+	current, err := readCI(api, args[0])
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration item %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	t, _ := current["type"].(string)
+	if err := checkParent(xld, api, t, args[1]); err != nil {
+		jww.FATAL.Printf("%s: can not move %s to %s: %s", cmd.CommandPath(), args[0], args[1], err)
+		os.Exit(1)
+	}
+
+	target := path.Join(args[1], path.Base(args[0]))
+	if err := checkFree(api, target); err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	err = api.do(http.MethodPost, "repository/move/"+args[0], url.Values{"to": {target}}, nil, &ci)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error moving %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	RenderJSON(ci)
+}
+
+// checkFree returns an error when a ci with the given id already exists
+func checkFree(api *apiClient, id string) error {
+	var exists bool
+
+	if err := api.do(http.MethodGet, "repository/exists/"+id, nil, nil, &exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s already exists", id)
+	}
+	return nil
+}
+
+// checkParent verifies that a ci of type t can be stored below parent according to the type metadata
+// types with a root live in that root or a directory in it, other types need a parent matching their containment property
+func checkParent(xld *goxldeploy.Client, api *apiClient, t, parent string) error {
+	tt, err := xld.Metadata.GetType(t)
+	if err != nil {
+		return err
+	}
+
+	parentType := "internal.Root"
+	if strings.Contains(parent, "/") {
+		p, err := readCI(api, parent)
+		if err != nil {
+			return err
+		}
+		parentType, _ = p["type"].(string)
+	}
+
+	root := strings.SplitN(parent, "/", 2)[0]
+
+	if tt.Root != "" && !strings.EqualFold(tt.Root, "NESTED") {
+		if !strings.EqualFold(tt.Root, root) {
+			return fmt.Errorf("%s belongs in %s, not in %s", t, tt.Root, root)
+		}
+		if parentType != "internal.Root" && parentType != "core.Directory" {
+			return fmt.Errorf("%s can only be stored in %s or a directory in it, %s is a %s", t, tt.Root, parent, parentType)
+		}
+		return nil
+	}
+
+	pt, err := xld.Metadata.GetType(parentType)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range tt.Properties {
+		if p.AsContainment && strings.EqualFold(p.Kind, "CI") {
+			if isA(pt, p.ReferencedType) {
+				return nil
+			}
+			return fmt.Errorf("%s has to be stored below a %s, %s is a %s", t, p.ReferencedType, parent, parentType)
+		}
+	}
+
+	return fmt.Errorf("%s has no root and no containing type", t)
+}
+
+// isA tells if a type is, extends or implements the given type name
+func isA(t goxldeploy.Type, name string) bool {
+	return t.Type == name || containsString(t.SuperTypes, name) || containsString(t.Interfaces, name)
+}
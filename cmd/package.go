@@ -0,0 +1,172 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// packageCmd represents the package command
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "handle deployment packages",
+	Long:  `import deployment packages (dar files) into xldeploy`,
+}
+
+var packageImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import a dar package from a file or url",
+	Long:  "usage: import <file.dar | url>\n       import --server-side <name>\nuploads a local dar file, lets xldeploy fetch it from an url or imports one of the server's importable packages and prints the imported application version",
+	Run:   importPackage,
+}
+
+var packageListImportableCmd = &cobra.Command{
+	Use:   "list-importable",
+	Short: "list the packages xldeploy can import from its importablePackages directory",
+	Run:   listImportablePackages,
+}
+
+var serverSideBool bool
+
+func init() {
+	packageImportCmd.Flags().BoolVar(&serverSideBool, "server-side", false, "import a package from the importablePackages directory of the server")
+	packageCmd.AddCommand(packageImportCmd)
+
+	packageCmd.AddCommand(packageListImportableCmd)
+
+	RootCmd.AddCommand(packageCmd)
+}
+
+func importPackage(cmd *cobra.Command, args []string) {
+	var ci map[string]interface{}
+	var err error
+
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a dar file, url or importable package name", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	if serverSideBool {
+		err = api.do(http.MethodPost, "package/import/"+args[0], nil, nil, &ci)
+	} else if strings.HasPrefix(args[0], "http://") || strings.HasPrefix(args[0], "https://") {
+		err = api.do(http.MethodPost, "package/fetch", nil, args[0], &ci)
+	} else {
+		ci, err = uploadPackage(api, args[0])
+	}
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error importing package %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	RenderJSON(ci)
+}
+
+func listImportablePackages(cmd *cobra.Command, args []string) {
+	var pl []string
+
+	api := getAPIClient()
+
+	err := api.do(http.MethodGet, "package/import", nil, nil, &pl)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error retrieving importable packages: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	RenderJSON(pl)
+}
+
+// uploadPackage streams a dar file to xldeploy as a multipart upload, reporting progress on stderr
+func uploadPackage(api *apiClient, file string) (map[string]interface{}, error) {
+	var ci map[string]interface{}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(file)
+	src := &progressReader{r: f, total: fi.Size(), name: name, out: os.Stderr}
+
+	// the multipart body is written through a pipe so large packages are never held in memory
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		part, err := mw.CreateFormFile("fileData", name)
+		if err == nil {
+			_, err = io.Copy(part, src)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	b, err := api.doRaw(http.MethodPost, "package/upload/"+name, nil, mw.FormDataContentType(), pr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &ci)
+	return ci, err
+}
+
+// progressReader reports every ten percent of a file that has been read
+type progressReader struct {
+	r     io.Reader
+	total int64
+	read  int64
+	step  int64
+	name  string
+	out   io.Writer
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	if p.total > 0 {
+		if s := p.read * 10 / p.total; s > p.step {
+			p.step = s
+			fmt.Fprintf(p.out, "%s: %d%% of %d bytes\n", p.name, s*10, p.total)
+		}
+	}
+
+	return n, err
+}
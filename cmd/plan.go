@@ -0,0 +1,185 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+var repositoryPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "show the differences between a directory of ci files and the repository",
+	Long:  "usage: plan --dir <path> [--no-color]\nshows per ci what apply would create or change and what exists in the repository but not on disk\nexits with 2 when apply would change something, 0 when it would not\ncis only in the repository are listed but do not count, apply never deletes them",
+	Run:   PlanCI,
+}
+
+var noColorBool bool
+
+func init() {
+	repositoryPlanCmd.Flags().StringVar(&dir, "dir", "", "directory to read the ci files from")
+	repositoryPlanCmd.Flags().BoolVar(&noColorBool, "no-color", false, "do not color the output")
+
+	repositoryCmd.AddCommand(repositoryPlanCmd)
+}
+
+func PlanCI(cmd *cobra.Command, args []string) {
+	var create, change, unmanaged int
+
+	if dir == "" {
+		jww.FATAL.Printf("%s: requires --dir", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	// only color when writing to a terminal, so ci job logs stay readable
+	color := !noColorBool
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		color = false
+	}
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	api := getAPIClient()
+	lookup := liveTypeLookup()
+
+	cis, err := loadCIFiles(dir, lookup)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	onDisk := make(map[string]bool)
+	for _, ci := range cis {
+		onDisk[ci["id"].(string)] = true
+	}
+
+	for _, ci := range cis {
+		i := ci["id"].(string)
+		t := ci["type"].(string)
+
+		tt, _ := lookup(t)
+
+		current, err := readCI(api, i)
+		if e, ok := err.(*apiError); ok && e.StatusCode == http.StatusNotFound {
+			// passwords are masked, plans end up in ci job logs
+			secret := make(map[string]bool)
+			for _, p := range tt.Properties {
+				secret[p.Name] = p.Password
+			}
+
+			fmt.Println(paint(colorGreen, fmt.Sprintf("+ %s (%s)", i, t)))
+			for _, k := range sortedKeys(ci) {
+				switch {
+				case k == "id" || k == "type":
+				case secret[k]:
+					fmt.Println(paint(colorGreen, fmt.Sprintf("    + %s: %s", k, passwordMask)))
+				default:
+					fmt.Println(paint(colorGreen, fmt.Sprintf("    + %s: %s", k, planValue(ci[k]))))
+				}
+			}
+			create++
+			continue
+		}
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration item %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+
+		changes := diffCI(tt, current, ci)
+		if len(changes) == 0 {
+			continue
+		}
+
+		fmt.Println(paint(colorYellow, fmt.Sprintf("~ %s (%s)", i, t)))
+		for _, c := range changes {
+			fmt.Printf("    %s %s: %s => %s\n", paint(colorYellow, "~"), c.Name, paint(colorRed, planValue(c.Old)), paint(colorGreen, planValue(c.New)))
+		}
+		change++
+	}
+
+	// everything in the repository below the top most cis on disk is expected to be on disk as well
+	for _, ci := range cis {
+		i := ci["id"].(string)
+		if onDisk[path.Dir(i)] {
+			continue
+		}
+
+		refs, err := queryCIs(api, url.Values{"ancestor": {i}, "resultsPerPage": {"-1"}})
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error retrieving the children of %s: %s", cmd.CommandPath(), i, err)
+			os.Exit(1)
+		}
+
+		for _, r := range refs {
+			if !onDisk[r.ID] {
+				fmt.Println(paint(colorRed, fmt.Sprintf("? %s (%s)", r.ID, r.Type)))
+				unmanaged++
+			}
+		}
+	}
+
+	fmt.Printf("\nPlan: %d to create, %d to change, %d only in the repository (left alone by apply)\n", create, change, unmanaged)
+
+	// apply never deletes, so cis only in the repository can not be resolved by it and do not fail the plan
+	if create+change != 0 {
+		os.Exit(2)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// planValue renders a property value compactly on one line
+func planValue(v interface{}) string {
+	if v == nil {
+		return "(not set)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
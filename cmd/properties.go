@@ -0,0 +1,416 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/viveleroy/goxldeploy"
+)
+
+// propertySyntax explains the property syntax in the help of the commands using it
+const propertySyntax = `properties are written as prop=val, values can be
+  "quoted" or 'quoted'  to include , = [ { and @, \ escapes the next character
+  [a,b]                 a set or list
+  {k:v,k2:v2}           a map
+  @path                 the contents of a file
+values are converted to the property kinds of the type`
+
+// commandLineProperties parses the comma seperated property arguments and the single --set properties
+func commandLineProperties(args []string, sets []string) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+
+	for _, a := range args {
+		ps, err := splitPropertiesString(a)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range ps {
+			properties[k] = v
+		}
+	}
+
+	// a --set holds exactly one property, so its unquoted value may contain commas
+	for _, a := range sets {
+		pp := &propertyParser{s: a, single: true}
+		k, v, err := pp.property()
+		if err == nil && !pp.done() {
+			err = pp.errorf("unexpected input after the value of %s", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("--set %s: %s", a, err)
+		}
+		properties[k] = v
+	}
+
+	return properties, nil
+}
+
+// splitPropertiesString parses a comma seperated list of prop=val
+func splitPropertiesString(s string) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+
+	pp := &propertyParser{s: s}
+	for !pp.done() {
+		k, v, err := pp.property()
+		if err != nil {
+			return nil, err
+		}
+		properties[k] = v
+
+		if !pp.done() && !pp.accept(',') {
+			return nil, pp.errorf("expected , after the value of %s", k)
+		}
+	}
+
+	return properties, nil
+}
+
+// propertyParser reads properties written as described in propertySyntax
+type propertyParser struct {
+	s      string
+	pos    int
+	single bool
+}
+
+func (pp *propertyParser) done() bool {
+	return pp.pos >= len(pp.s)
+}
+
+func (pp *propertyParser) peek() byte {
+	if pp.done() {
+		return 0
+	}
+	return pp.s[pp.pos]
+}
+
+func (pp *propertyParser) accept(c byte) bool {
+	if pp.peek() == c && !pp.done() {
+		pp.pos++
+		return true
+	}
+	return false
+}
+
+func (pp *propertyParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("position %d of %q: %s", pp.pos+1, pp.s, fmt.Sprintf(format, a...))
+}
+
+// property reads a single prop=val
+func (pp *propertyParser) property() (string, interface{}, error) {
+	k, err := pp.word("=,")
+	if err != nil {
+		return "", nil, err
+	}
+	k = strings.TrimSpace(k)
+	if k == "" || !pp.accept('=') {
+		return "", nil, pp.errorf("expected prop=val")
+	}
+
+	switch pp.peek() {
+	case '[':
+		pp.pos++
+		l, err := pp.list()
+		return k, l, err
+	case '{':
+		pp.pos++
+		m, err := pp.dict()
+		return k, m, err
+	case '@':
+		pp.pos++
+		f, err := pp.value(",")
+		if err != nil {
+			return "", nil, err
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", nil, err
+		}
+		return k, string(b), nil
+	}
+
+	v, err := pp.value(",")
+	return k, v, err
+}
+
+// list reads the items of [a,b] after the opening bracket
+func (pp *propertyParser) list() ([]string, error) {
+	l := []string{}
+
+	if pp.accept(']') {
+		return l, nil
+	}
+
+	for {
+		v, err := pp.value(",]")
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+
+		if pp.accept(']') {
+			return l, nil
+		}
+		if !pp.accept(',') {
+			return nil, pp.errorf("expected , or ] in list")
+		}
+	}
+}
+
+// dict reads the entries of {k:v} after the opening brace
+func (pp *propertyParser) dict() (map[string]string, error) {
+	m := make(map[string]string)
+
+	if pp.accept('}') {
+		return m, nil
+	}
+
+	for {
+		k, err := pp.word(":,}")
+		if err != nil {
+			return nil, err
+		}
+		if !pp.accept(':') {
+			return nil, pp.errorf("expected key:value in map")
+		}
+		v, err := pp.value(",}")
+		if err != nil {
+			return nil, err
+		}
+		m[strings.TrimSpace(k)] = v
+
+		if pp.accept('}') {
+			return m, nil
+		}
+		if !pp.accept(',') {
+			return nil, pp.errorf("expected , or } in map")
+		}
+	}
+}
+
+// value reads a quoted value or an unquoted one up to one of the stop characters
+// in single mode a top level value runs to the end of the input
+func (pp *propertyParser) value(stop string) (string, error) {
+	for pp.peek() == ' ' {
+		pp.pos++
+	}
+
+	if q := pp.peek(); q == '"' || q == '\'' {
+		pp.pos++
+		var b bytes.Buffer
+		for {
+			if pp.done() {
+				return "", pp.errorf("unterminated quote")
+			}
+			c := pp.s[pp.pos]
+			pp.pos++
+			switch {
+			case c == q:
+				for pp.peek() == ' ' {
+					pp.pos++
+				}
+				return b.String(), nil
+			case c == '\\' && !pp.done():
+				b.WriteByte(unescape(pp.s[pp.pos]))
+				pp.pos++
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+
+	if pp.single && stop == "," {
+		stop = ""
+	}
+
+	v, err := pp.word(stop)
+	return strings.TrimSpace(v), err
+}
+
+// word reads up to one of the stop characters, honouring backslash escapes
+func (pp *propertyParser) word(stop string) (string, error) {
+	var b bytes.Buffer
+
+	for !pp.done() {
+		c := pp.s[pp.pos]
+		if strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		pp.pos++
+		if c == '\\' {
+			if pp.done() {
+				return "", pp.errorf("escape at end of input")
+			}
+			c = unescape(pp.s[pp.pos])
+			pp.pos++
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String(), nil
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	}
+	return c
+}
+
+// coerceProperties converts the values of props to the kinds declared by the type metadata
+// properties the type does not know are refused, so typos do not silently disappear
+// a null value leaves the property unset
+func coerceProperties(t goxldeploy.Type, props map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+
+	descriptors := make(map[string]goxldeploy.PropertyDescriptor)
+	for _, p := range t.Properties {
+		descriptors[p.Name] = p
+	}
+
+	for k, v := range props {
+		p, ok := descriptors[k]
+		if !ok {
+			return nil, fmt.Errorf("type %s has no property %s", t.Type, k)
+		}
+		if v == nil {
+			continue
+		}
+		cv, err := coerceValue(p, v)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %s", k, err)
+		}
+		out[k] = cv
+	}
+
+	return out, nil
+}
+
+// coerceValue converts a single value to the kind of the property
+// values can be as parsed from the command line, see propertySyntax, or already typed values as read from a json file
+func coerceValue(p goxldeploy.PropertyDescriptor, v interface{}) (interface{}, error) {
+	switch strings.ToUpper(p.Kind) {
+	case "BOOLEAN":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			return strconv.ParseBool(b)
+		}
+
+	case "INTEGER":
+		switch i := v.(type) {
+		case float64:
+			if i != math.Trunc(i) {
+				return nil, fmt.Errorf("%v is not a whole number", i)
+			}
+			return int(i), nil
+		case int:
+			return i, nil
+		case string:
+			return strconv.Atoi(i)
+		}
+
+	case "ENUM":
+		s := fmt.Sprint(v)
+		if len(p.EnumValues) != 0 && !containsString(p.EnumValues, s) {
+			return nil, fmt.Errorf("%q is not one of %s", s, strings.Join(p.EnumValues, ", "))
+		}
+		return s, nil
+
+	case "STRING", "DATE":
+		switch v.(type) {
+		case []string, []interface{}, map[string]string, map[string]interface{}:
+			return nil, fmt.Errorf("expected a single value for kind %s, got %v", p.Kind, v)
+		}
+		return fmt.Sprint(v), nil
+
+	case "CI":
+		return ciReference(v)
+
+	case "SET_OF_STRING", "LIST_OF_STRING", "SET_OF_CI", "LIST_OF_CI":
+		var items []interface{}
+		switch l := v.(type) {
+		case []interface{}:
+			items = l
+		case []string:
+			for _, s := range l {
+				items = append(items, s)
+			}
+		default:
+			return nil, fmt.Errorf("expected a list for kind %s, got %v", p.Kind, v)
+		}
+
+		out := []string{}
+		for _, i := range items {
+			if strings.HasSuffix(strings.ToUpper(p.Kind), "_OF_CI") {
+				r, err := ciReference(i)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, r)
+				continue
+			}
+			out = append(out, fmt.Sprint(i))
+		}
+		return out, nil
+
+	case "MAP_STRING_STRING":
+		out := make(map[string]string)
+		switch m := v.(type) {
+		case map[string]interface{}:
+			for k, mv := range m {
+				out[k] = fmt.Sprint(mv)
+			}
+		case map[string]string:
+			return m, nil
+		default:
+			return nil, fmt.Errorf("expected a map for kind %s, got %v", p.Kind, v)
+		}
+		return out, nil
+
+	default:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("can not use %v as %s", v, strings.ToLower(p.Kind))
+}
+
+// ciReference returns the id of a referenced ci, which can be given as id or as ci object
+func ciReference(v interface{}) (string, error) {
+	switch r := v.(type) {
+	case string:
+		return r, nil
+	case map[string]interface{}:
+		if id, ok := r["id"].(string); ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("can not use %v as ci reference", v)
+}
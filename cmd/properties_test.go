@@ -0,0 +1,164 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPropertiesString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]interface{}
+	}{
+		{`a=1`, map[string]interface{}{"a": "1"}},
+		{`a=1,b=two`, map[string]interface{}{"a": "1", "b": "two"}},
+		{` a = 1 , b = 2 `, map[string]interface{}{"a": "1", "b": "2"}},
+		{`a=`, map[string]interface{}{"a": ""}},
+		// = after the first one belongs to the value
+		{`url=http://host/x?a=b`, map[string]interface{}{"url": "http://host/x?a=b"}},
+		{`url="http://host/x?a=b,c=d",n=1`, map[string]interface{}{"url": "http://host/x?a=b,c=d", "n": "1"}},
+		{`a='single "quoted"'`, map[string]interface{}{"a": `single "quoted"`}},
+		{`a="say \"hi\""`, map[string]interface{}{"a": `say "hi"`}},
+		{`a="tab\there\n"`, map[string]interface{}{"a": "tab\there\n"}},
+		{`a=x\,y`, map[string]interface{}{"a": "x,y"}},
+		{`k\=ey=v`, map[string]interface{}{"k=ey": "v"}},
+		{`a=[]`, map[string]interface{}{"a": []string{}}},
+		{`a=[x,y],b=z`, map[string]interface{}{"a": []string{"x", "y"}, "b": "z"}},
+		{`a=[ x , "y,z" ]`, map[string]interface{}{"a": []string{"x", "y,z"}}},
+		{`a={}`, map[string]interface{}{"a": map[string]string{}}},
+		{`a={k:v,k2:"v:2"}`, map[string]interface{}{"a": map[string]string{"k": "v", "k2": "v:2"}}},
+		{`a={url:"http://h/?q=1,2"},b=[1]`, map[string]interface{}{"a": map[string]string{"url": "http://h/?q=1,2"}, "b": []string{"1"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitPropertiesString(tt.in)
+		if err != nil {
+			t.Errorf("splitPropertiesString(%q) returned error %s", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPropertiesString(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPropertiesStringErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`a`, `position 2 of "a": expected prop=val`},
+		{`=1`, `position 1 of "=1": expected prop=val`},
+		{`a=1,,b=2`, `position 5 of "a=1,,b=2": expected prop=val`},
+		{`a="open`, `position 8 of "a=\"open": unterminated quote`},
+		{`a="x"y`, `position 6 of "a=\"x\"y": expected , after the value of a`},
+		{`a="x" y`, `position 7 of "a=\"x\" y": expected , after the value of a`},
+		{`a=[x`, `position 5 of "a=[x": expected , or ] in list`},
+		{`a=[x;y]b`, `position 8 of "a=[x;y]b": expected , after the value of a`},
+		{`a={k}`, `position 5 of "a={k}": expected key:value in map`},
+		{`a={k:v`, `position 7 of "a={k:v": expected , or } in map`},
+		{`a=x\`, `position 5 of "a=x\\": escape at end of input`},
+	}
+
+	for _, tt := range tests {
+		_, err := splitPropertiesString(tt.in)
+		if err == nil {
+			t.Errorf("splitPropertiesString(%q) returned no error", tt.in)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("splitPropertiesString(%q) error = %q, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestCommandLineProperties(t *testing.T) {
+	d, err := ioutil.TempDir("", "xldc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	f := filepath.Join(d, "script.sh")
+	if err := ioutil.WriteFile(f, []byte("echo a,b=c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		sets []string
+		want map[string]interface{}
+	}{
+		{nil, nil, map[string]interface{}{}},
+		{[]string{"a=1", "b=2,c=3"}, nil, map[string]interface{}{"a": "1", "b": "2", "c": "3"}},
+		{[]string{"script=@" + f}, nil, map[string]interface{}{"script": "echo a,b=c\n"}},
+		{[]string{`script=@"` + f + `",a=1`}, nil, map[string]interface{}{"script": "echo a,b=c\n", "a": "1"}},
+		// a --set holds a single property, so an unquoted value may contain commas
+		{nil, []string{"url=http://h/?a=1,b=2"}, map[string]interface{}{"url": "http://h/?a=1,b=2"}},
+		{nil, []string{"a=x,y", "b=[x,y]"}, map[string]interface{}{"a": "x,y", "b": []string{"x", "y"}}},
+		{nil, []string{"a={k:v}"}, map[string]interface{}{"a": map[string]string{"k": "v"}}},
+		{nil, []string{"script=@" + f}, map[string]interface{}{"script": "echo a,b=c\n"}},
+		// --set is applied after the positional properties
+		{[]string{"a=1,b=2"}, []string{"a=3"}, map[string]interface{}{"a": "3", "b": "2"}},
+	}
+
+	for _, tt := range tests {
+		got, err := commandLineProperties(tt.args, tt.sets)
+		if err != nil {
+			t.Errorf("commandLineProperties(%q, %q) returned error %s", tt.args, tt.sets, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandLineProperties(%q, %q) = %#v, want %#v", tt.args, tt.sets, got, tt.want)
+		}
+	}
+}
+
+func TestCommandLinePropertiesErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		sets []string
+		want string
+	}{
+		{[]string{"a=@does-not-exist"}, nil, "open does-not-exist: no such file or directory"},
+		{nil, []string{"novalue"}, `--set novalue: position 8 of "novalue": expected prop=val`},
+		{nil, []string{`a="x`}, `--set a="x: position 5 of "a=\"x": unterminated quote`},
+		{nil, []string{`a="x",b=2`}, `--set a="x",b=2: position 6 of "a=\"x\",b=2": unexpected input after the value of a`},
+		{nil, []string{`a=[x,y]junk`}, `--set a=[x,y]junk: position 8 of "a=[x,y]junk": unexpected input after the value of a`},
+		{nil, []string{`a={k:v} trailing`}, `--set a={k:v} trailing: position 8 of "a={k:v} trailing": unexpected input after the value of a`},
+	}
+
+	for _, tt := range tests {
+		_, err := commandLineProperties(tt.args, tt.sets)
+		if err == nil {
+			t.Errorf("commandLineProperties(%q, %q) returned no error", tt.args, tt.sets)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("commandLineProperties(%q, %q) error = %q, want %q", tt.args, tt.sets, err, tt.want)
+		}
+	}
+}
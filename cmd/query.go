@@ -0,0 +1,149 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var repositoryQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "search the repository",
+	Long:  "usage: query [--type <type>] [--parent <id>] [--ancestor <id>] [--name <pattern>] [--modified-after <date>] [--modified-before <date>] [--page <n> --per-page <n>] [--long]\nname patterns use * as wildcard, dates are written as 2017-11-30T12:00:00.000+0100",
+	Run:   QueryCI,
+}
+
+var queryType string
+var queryParent string
+var queryAncestor string
+var queryName string
+var queryAfter string
+var queryBefore string
+var queryPage int
+var queryPerPage int
+
+func init() {
+	repositoryQueryCmd.Flags().StringVarP(&queryType, "type", "t", "", "only cis of this type or its subtypes")
+	repositoryQueryCmd.Flags().StringVar(&queryParent, "parent", "", "only direct children of this ci")
+	repositoryQueryCmd.Flags().StringVar(&queryAncestor, "ancestor", "", "only cis below this ci")
+	repositoryQueryCmd.Flags().StringVarP(&queryName, "name", "n", "", "only cis whose name matches this pattern")
+	repositoryQueryCmd.Flags().StringVar(&queryAfter, "modified-after", "", "only cis modified after this date")
+	repositoryQueryCmd.Flags().StringVar(&queryBefore, "modified-before", "", "only cis modified before this date")
+	repositoryQueryCmd.Flags().IntVar(&queryPage, "page", 0, "page of the results to return")
+	repositoryQueryCmd.Flags().IntVar(&queryPerPage, "per-page", -1, "results per page, -1 returns everything")
+	repositoryQueryCmd.Flags().BoolVarP(&longBool, "long", "l", false, "print the full cis instead of their ids")
+
+	repositoryCmd.AddCommand(repositoryQueryCmd)
+}
+
+func QueryCI(cmd *cobra.Command, args []string) {
+	//Lets declare an interface for output
+	var o interface{}
+
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("type", queryType)
+	set("parent", queryParent)
+	set("ancestor", queryAncestor)
+	set("namePattern", strings.Replace(queryName, "*", "%", -1))
+	set("lastModifiedAfter", queryAfter)
+	set("lastModifiedBefore", queryBefore)
+	set("page", strconv.Itoa(queryPage))
+	set("resultsPerPage", strconv.Itoa(queryPerPage))
+
+	api := getAPIClient()
+
+	refs, err := queryCIs(api, q)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error querying the repository: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	ids := []string{}
+	for _, r := range refs {
+		ids = append(ids, r.ID)
+	}
+	o = ids
+
+	if longBool && len(ids) != 0 {
+		o, err = readCIs(api, ids)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration items: %s", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+	}
+
+	if outputFile != "" {
+		WriteJSONToFile(o, outputFile)
+		os.Exit(0)
+	}
+
+	RenderJSON(o)
+}
+
+// ciRef is a single result of a repository query
+type ciRef struct {
+	ID   string `json:"ref"`
+	Type string `json:"type"`
+}
+
+// queryCIs runs a repository query, q takes the parameters of the xldeploy query api (type, parent, ancestor ...)
+func queryCIs(api *apiClient, q url.Values) ([]ciRef, error) {
+	var refs []ciRef
+	err := api.do(http.MethodGet, "repository/query", q, nil, &refs)
+	return refs, err
+}
+
+// readBatchSize is the maximum number of cis retrieved with a single read request
+const readBatchSize = 100
+
+// readCIs retrieves several cis, in batches of readBatchSize so large selections do not turn into one enormous request
+func readCIs(api *apiClient, ids []string) ([]map[string]interface{}, error) {
+	var cis []map[string]interface{}
+
+	for len(ids) != 0 {
+		n := len(ids)
+		if n > readBatchSize {
+			n = readBatchSize
+		}
+
+		var batch []map[string]interface{}
+		err := api.do(http.MethodPost, "repository/cis/read", nil, ids[:n], &batch)
+		if err != nil {
+			return nil, err
+		}
+		cis = append(cis, batch...)
+		ids = ids[n:]
+	}
+
+	return cis, nil
+}
@@ -21,6 +21,9 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
@@ -32,6 +35,10 @@ import (
 
 var id string
 var ciType string
+var inputFile string
+var merge bool
+var setProperties []string
+var interactiveBool bool
 
 // verifyCmd represents the verify command
 var repositoryCmd = &cobra.Command{
@@ -43,6 +50,7 @@ var repositoryCmd = &cobra.Command{
 var repositoryUpdateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "update an already existing ci in the repository",
+	Long:  "usage: update --id <id> [--in <file.json|file.yaml>] [--merge] [--set prop=val]... <comma seperated list prop=val>\nonly the given properties are changed, with --merge collection properties are merged instead of replaced\n" + propertySyntax,
 	Run:   UpdateCI,
 }
 
@@ -55,23 +63,31 @@ var repositoryGetCmd = &cobra.Command{
 var repositoryCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "create a ci in the xld repository",
-	Long:  "usage: create --id <id> --type <type> <comma seperated list prop=val",
+	Long:  "usage: create --id <id> --type <type> [--in <file.json|file.yaml>] [--set prop=val]... <comma seperated list prop=val>\n       create --interactive --type <type> [--id <id>]\n" + propertySyntax,
 	Run:   CreateCI,
 }
 
 func init() {
 	// add flags to the various previously defined commands
 	repositoryGetCmd.Flags().StringVarP(&outputFile, "out", "", "", "specify an output file")
+	// get never read --in, it is kept so existing scripts passing it do not break
 	repositoryGetCmd.Flags().StringVarP(&inputFile, "in", "", "", "specify an input file containing")
+	repositoryGetCmd.Flags().MarkDeprecated("in", "it is ignored by get and will be removed")
 
 	repositoryCmd.AddCommand(repositoryGetCmd)
 
 	repositoryUpdateCmd.Flags().BoolVarP(&merge, "merge", "m", false, "merge the update with the existing ci")
+	repositoryUpdateCmd.Flags().StringVarP(&id, "id", "i", "", "specify ci id")
+	repositoryUpdateCmd.Flags().StringVarP(&inputFile, "in", "", "", "specify a json or yaml file containing the properties to update")
+	repositoryUpdateCmd.Flags().StringArrayVar(&setProperties, "set", nil, "set a single property, prop=val, can be repeated")
 	repositoryCmd.AddCommand(repositoryUpdateCmd)
 	// add the commands to da mothership
 
 	repositoryCreateCmd.Flags().StringVarP(&id, "id", "i", "", "specify ci id")
 	repositoryCreateCmd.Flags().StringVarP(&ciType, "type", "t", "", "specify ci type")
+	repositoryCreateCmd.Flags().StringVarP(&inputFile, "in", "", "", "specify a json or yaml file containing the ci")
+	repositoryCreateCmd.Flags().StringArrayVar(&setProperties, "set", nil, "set a single property, prop=val, can be repeated")
+	repositoryCreateCmd.Flags().BoolVar(&interactiveBool, "interactive", false, "ask for the properties of the type one by one")
 	repositoryCmd.AddCommand(repositoryCreateCmd)
 
 	RootCmd.AddCommand(repositoryCmd)
@@ -103,19 +119,94 @@ func GetCI(cmd *cobra.Command, args []string) {
 
 func CreateCI(cmd *cobra.Command, args []string) {
 
-	var ci goxldeploy.Ci
+	spec := make(map[string]interface{})
 
 	xld := GetClient()
 
-	if len(args) != 0 {
-		ci = goxldeploy.NewCI(id, ciType, splitPropertiesString(args[0]))
+	if inputFile != "" {
+		f, err := readCIFile(inputFile)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), inputFile, err)
+			os.Exit(1)
+		}
+		// an empty id (as in a template) leaves the id to the --id flag
+		for k, v := range f {
+			if k == "id" && v == "" {
+				continue
+			}
+			spec[k] = v
+		}
+	}
+
+	if id != "" {
+		spec["id"] = id
+	}
+	if ciType != "" {
+		spec["type"] = ciType
+	}
+	cliProps, err := commandLineProperties(args, setProperties)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+	for k, v := range cliProps {
+		spec[k] = v
+	}
+
+	var w wizard
+	var tt goxldeploy.Type
+	if interactiveBool {
+		if ciType == "" {
+			jww.FATAL.Printf("%s: --interactive requires --type", cmd.CommandPath())
+			os.Exit(1)
+		}
+
+		tt, err = xld.Metadata.GetType(ciType)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata for %s: %s", cmd.CommandPath(), ciType, err)
+			os.Exit(1)
+		}
+
+		w = newWizard()
+		answers, err := w.create(tt, id)
+		if err != nil {
+			jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+		for k, v := range answers {
+			spec[k] = v
+		}
+	}
+
+	ci, err := buildCI(xld, spec)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
 	}
 
-	ci = goxldeploy.NewCI(id, ciType, nil)
+	if interactiveBool {
+		// the passwords were read without echo, so they are not shown here either
+		summary := make(map[string]interface{})
+		for k, v := range spec {
+			summary[k] = v
+		}
+		for _, p := range tt.Properties {
+			if _, ok := summary[p.Name]; ok && p.Password {
+				summary[p.Name] = passwordMask
+			}
+		}
+
+		b, _ := json.MarshalIndent(summary, "", " ")
+		fmt.Fprintf(os.Stderr, "\n%s\n", b)
+		if !w.confirm("create this ci?") {
+			fmt.Fprintln(os.Stderr, "nothing created")
+			os.Exit(1)
+		}
+	}
 
-	ci, err := xld.Repository.CreateCI(ci)
+	ci, err = xld.Repository.CreateCI(ci)
 	if err != nil {
-		jww.FATAL.Printf("%s: encounterd a fatal error creating configuration Item %s: %s", cmd.CommandPath(), id, err)
+		jww.FATAL.Printf("%s: encounterd a fatal error creating configuration Item %v: %s", cmd.CommandPath(), spec["id"], err)
 		os.Exit(1)
 	}
 
@@ -123,35 +214,161 @@ func CreateCI(cmd *cobra.Command, args []string) {
 
 }
 
-func UpdateCI(cmd *cobra.Command, args []string) {
+// buildCI turns a flat id, type and properties map into a ci with properties typed after the metadata
+// keys starting with $ are server administration ($token, $createdBy ...) and are left out
+func buildCI(xld *goxldeploy.Client, spec map[string]interface{}) (goxldeploy.Ci, error) {
 	var ci goxldeploy.Ci
 
+	i, _ := spec["id"].(string)
+	t, _ := spec["type"].(string)
+	if i == "" || t == "" {
+		return ci, fmt.Errorf("a ci requires an id and a type")
+	}
+
+	props := make(map[string]interface{})
+	for k, v := range spec {
+		if k == "id" || k == "type" || strings.HasPrefix(k, "$") {
+			continue
+		}
+		props[k] = v
+	}
+
+	tt, err := xld.Metadata.GetType(t)
+	if err != nil {
+		return ci, fmt.Errorf("encounterd a fatal error in retrieving metadata for %s: %s", t, err)
+	}
+
+	props, err = coerceProperties(tt, props)
+	if err != nil {
+		return ci, err
+	}
+
+	return goxldeploy.NewCI(i, t, props), nil
+}
+
+func UpdateCI(cmd *cobra.Command, args []string) {
+
+	props := make(map[string]interface{})
+
+	if id == "" {
+		jww.FATAL.Printf("%s: requires a ci id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	if inputFile != "" {
+		f, err := readCIFile(inputFile)
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error reading %s: %s", cmd.CommandPath(), inputFile, err)
+			os.Exit(1)
+		}
+		props = f
+		for k := range props {
+			if k == "id" || k == "type" || strings.HasPrefix(k, "$") {
+				delete(props, k)
+			}
+		}
+	}
+	cliProps, err := commandLineProperties(args, setProperties)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+	for k, v := range cliProps {
+		props[k] = v
+	}
+
 	xld := GetClient()
+	api := getAPIClient()
+
+	// the ci is read as a plain map so the $token comes along and xldeploy can detect concurrent changes
+	current, err := readCI(api, id)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration item %s: %s", cmd.CommandPath(), id, err)
+		os.Exit(1)
+	}
 
-	if len(args) != 0 {
-		ci = goxldeploy.NewCI(id, ciType, splitPropertiesString(args[0]))
+	t, _ := current["type"].(string)
+	tt, err := xld.Metadata.GetType(t)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata for %s: %s", cmd.CommandPath(), t, err)
+		os.Exit(1)
+	}
+
+	props, err = coerceProperties(tt, props)
+	if err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
 	}
 
-	ci = goxldeploy.NewCI(id, ciType, nil)
+	for k, v := range props {
+		if merge {
+			v = mergeValue(current[k], v)
+		}
+		current[k] = v
+	}
 
-	ci, err := xld.Repository.CreateCI(ci)
+	var ci map[string]interface{}
+	err = api.do(http.MethodPut, "repository/ci/"+id, nil, current, &ci)
+	if e, ok := err.(*apiError); ok && e.StatusCode == http.StatusConflict {
+		jww.FATAL.Printf("%s: conflict, %s was changed by someone else after it was read, nothing was updated", cmd.CommandPath(), id)
+		os.Exit(1)
+	}
 	if err != nil {
-		jww.FATAL.Printf("%s: encounterd a fatal error creating configuration Item %s: %s", cmd.CommandPath(), id, err)
+		jww.FATAL.Printf("%s: encounterd a fatal error updating configuration Item %s: %s", cmd.CommandPath(), id, err)
 		os.Exit(1)
 	}
 
 	RenderJSON(ci)
 }
 
-func splitPropertiesString(s string) map[string]interface{} {
-	var properties map[string]interface{}
+// readCI retrieves a ci as a plain map, including the $ administration properties
+func readCI(api *apiClient, id string) (map[string]interface{}, error) {
+	var ci map[string]interface{}
+	err := api.do(http.MethodGet, "repository/ci/"+id, nil, nil, &ci)
+	return ci, err
+}
 
-	ps := strings.Split(s, ",")
-	for _, p := range ps {
-		kv := strings.Split(p, "=")
-		properties[kv[0]] = kv[1]
+// storeCI creates (POST) or updates (PUT) a ci given as plain map
+func storeCI(api *apiClient, method string, ci map[string]interface{}) error {
+	i, _ := ci["id"].(string)
+	return api.do(method, "repository/ci/"+i, nil, ci, nil)
+}
 
+// mergeValue merges a new collection value into the current one
+// lists and sets are extended with the items not yet present, maps get the new entries
+// anything else is replaced
+func mergeValue(current, v interface{}) interface{} {
+	switch nv := v.(type) {
+	case []string:
+		cl, ok := current.([]interface{})
+		if !ok {
+			return v
+		}
+		out := []string{}
+		for _, c := range cl {
+			if r, err := ciReference(c); err == nil {
+				out = append(out, r)
+			}
+		}
+		for _, n := range nv {
+			if !containsString(out, n) {
+				out = append(out, n)
+			}
+		}
+		return out
+	case map[string]string:
+		cm, ok := current.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		out := make(map[string]string)
+		for k, c := range cm {
+			out[k] = fmt.Sprint(c)
+		}
+		for k, n := range nv {
+			out[k] = n
+		}
+		return out
 	}
-
-	return properties
+	return v
 }
@@ -0,0 +1,65 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "roll back a deployment task",
+	Long:  "usage: rollback <task id>\ncreates and starts the rollback task for a failed or unwanted deployment task",
+	Run:   rollback,
+}
+
+func init() {
+	rollbackCmd.Flags().BoolVarP(&waitBool, "wait", "w", false, "wait for the rollback task to finish")
+	rollbackCmd.Flags().DurationVar(&pollInterval, "interval", 2*time.Second, "time between two polls of the task state")
+	rollbackCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "maximum time to wait for the task, 0 waits forever")
+
+	RootCmd.AddCommand(rollbackCmd)
+}
+
+func rollback(cmd *cobra.Command, args []string) {
+	var id string
+
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a task id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	err := api.do(http.MethodPost, "deployment/rollback/"+args[0], nil, nil, &id)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error creating the rollback task for %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	runTask(cmd, api, id)
+}
@@ -30,6 +30,7 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 	"github.com/viveleroy/goxldeploy"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // vars for app
@@ -67,7 +68,7 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(setVerbose, initConfig, processConfig, checkRequiredFlags)
+	cobra.OnInitialize(setVerbose, initConfig, processConfig)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -174,8 +175,11 @@ func processConfig() {
 	}
 }
 
-// preVerifyConnection will check if the connection can be established
+// preVerifyConnection will check the connection flags and if the connection can be established
+// commands that can work offline override it
 func preVerifyConnection(cmd *cobra.Command, args []string) {
+	checkRequiredFlags()
+
 	cfg := goxldeploy.Config{
 		User:     username,
 		Password: password,
@@ -210,7 +214,15 @@ func RenderJSON(l interface{}) {
 //GetClient returns a configured XLD client object
 func GetClient() *goxldeploy.Client {
 
-	cfg := goxldeploy.Config{
+	cfg := clientConfig()
+
+	return goxldeploy.New(&cfg)
+
+}
+
+// clientConfig returns the connection settings given by flags and config file
+func clientConfig() goxldeploy.Config {
+	return goxldeploy.Config{
 		User:     username,
 		Password: password,
 		Host:     host,
@@ -218,9 +230,41 @@ func GetClient() *goxldeploy.Client {
 		Context:  context,
 		Scheme:   scheme,
 	}
+}
 
-	return goxldeploy.New(&cfg)
+// profileConfig returns the connection settings of a profile in the config file
+// settings the profile does not define are taken from the current connection
+func profileConfig(name string) (goxldeploy.Config, error) {
+	cfg := clientConfig()
+
+	p := viper.Sub("profiles." + name)
+	if p == nil {
+		return cfg, fmt.Errorf("profile %s not found in %s", name, viper.ConfigFileUsed())
+	}
 
+	if p.IsSet("username") {
+		cfg.User = p.GetString("username")
+	}
+	if p.IsSet("password") {
+		cfg.Password = p.GetString("password")
+	}
+	if p.IsSet("host") {
+		cfg.Host = p.GetString("host")
+	}
+	if p.IsSet("port") {
+		cfg.Port = p.GetInt("port")
+	}
+	if p.IsSet("context") {
+		cfg.Context = p.GetString("context")
+	}
+	if p.IsSet("ssl") {
+		cfg.Scheme = "http"
+		if p.GetBool("ssl") {
+			cfg.Scheme = "https"
+		}
+	}
+
+	return cfg, nil
 }
 
 //WriteToFile writes any string output to file
@@ -243,3 +287,18 @@ func WriteJSONToFile(l interface{}, f string) {
 		panic(err)
 	}
 }
+
+//WriteYAMLToFile writes any output to file as yaml
+func WriteYAMLToFile(l interface{}, f string) {
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(f, b, 0644)
+
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/viveleroy/goxldeploy"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var metaSchemaCommand = &cobra.Command{
+	Use:   "schema",
+	Short: "Display a json schema for ci files",
+	Long:  "usage: schema [type...] [--dir <path>]\ngenerates a json schema for the files used by repository create --in, export and apply, covering all types when none are given\nwith --dir one <type>.schema.json is written per type instead of a single bundle",
+	Run:   getTypeSchema,
+}
+
+func init() {
+	metaSchemaCommand.Flags().StringVar(&dir, "dir", "", "write one schema file per type to this directory")
+
+	metaCmd.AddCommand(metaSchemaCommand)
+}
+
+func getTypeSchema(cmd *cobra.Command, args []string) {
+	var tl goxldeploy.TypeList
+	var err error
+
+	xld := GetClient()
+
+	if len(args) == 0 {
+		tl, err = xld.Metadata.GetTypeList()
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata: %s", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+	} else {
+		for _, t := range args {
+			tt, err := xld.Metadata.GetType(t)
+			if err != nil {
+				jww.FATAL.Printf("%s: encounterd a fatal error in retrieving metadata for %s: %s", cmd.CommandPath(), t, err)
+				os.Exit(1)
+			}
+			tl = append(tl, tt)
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, t := range tl {
+		known[t.Type] = true
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error creating %s: %s", cmd.CommandPath(), dir, err)
+			os.Exit(1)
+		}
+
+		for _, t := range tl {
+			s := typeSchema(t, known, func(r string) string { return r + ".schema.json" })
+			s["$schema"] = jsonSchemaDraft
+			s["$id"] = t.Type + ".schema.json"
+
+			f := filepath.Join(dir, t.Type+".schema.json")
+			WriteJSONToFile(s, f)
+			fmt.Println(f)
+		}
+		return
+	}
+
+	definitions := make(map[string]interface{})
+	var oneOf []interface{}
+	for _, t := range tl {
+		definitions[t.Type] = typeSchema(t, known, func(r string) string { return "#/definitions/" + r })
+		oneOf = append(oneOf, map[string]interface{}{"$ref": "#/definitions/" + t.Type})
+	}
+
+	o := map[string]interface{}{
+		"$schema":     jsonSchemaDraft,
+		"title":       "XL-Deploy configuration item",
+		"definitions": definitions,
+		"oneOf":       oneOf,
+	}
+
+	if outputFile != "" {
+		WriteJSONToFile(o, outputFile)
+		os.Exit(0)
+	}
+
+	RenderJSON(o)
+}
+
+// typeSchema converts the property descriptors of a type into a json schema object
+// ci references are ids or embedded cis, the latter are $refs when the referenced type is part of the schema
+func typeSchema(t goxldeploy.Type, known map[string]bool, ref func(string) string) map[string]interface{} {
+	required := []string{"id", "type"}
+	properties := map[string]interface{}{
+		"id":   map[string]interface{}{"type": "string", "description": "id of the ci, including its parents"},
+		"type": map[string]interface{}{"enum": []string{t.Type}},
+	}
+
+	reference := func(r string) map[string]interface{} {
+		id := map[string]interface{}{"type": "string", "description": "id of a " + r}
+		if !known[r] {
+			return id
+		}
+		return map[string]interface{}{"anyOf": []interface{}{id, map[string]interface{}{"$ref": ref(r)}}}
+	}
+
+	for _, p := range t.Properties {
+		var s map[string]interface{}
+
+		kind := strings.ToUpper(p.Kind)
+
+		// the containing ci follows from the id
+		if p.AsContainment && kind == "CI" {
+			continue
+		}
+
+		switch kind {
+		case "BOOLEAN":
+			s = map[string]interface{}{"type": "boolean"}
+		case "INTEGER":
+			s = map[string]interface{}{"type": "integer"}
+		case "DATE":
+			s = map[string]interface{}{"type": "string", "format": "date-time"}
+		case "ENUM":
+			s = map[string]interface{}{"type": "string", "enum": p.EnumValues}
+		case "SET_OF_STRING", "LIST_OF_STRING":
+			s = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+		case "MAP_STRING_STRING":
+			s = map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}}
+		case "CI":
+			s = reference(p.ReferencedType)
+		case "SET_OF_CI", "LIST_OF_CI":
+			s = map[string]interface{}{"type": "array", "items": reference(p.ReferencedType)}
+		default:
+			s = map[string]interface{}{"type": "string"}
+		}
+
+		if strings.HasPrefix(kind, "SET_OF_") {
+			s["uniqueItems"] = true
+		}
+		if p.Label != "" {
+			s["title"] = p.Label
+		}
+		if p.Description != "" {
+			s["description"] = p.Description
+		}
+		if p.Password {
+			s["writeOnly"] = true
+		}
+		if p.Default != nil {
+			if v, err := coerceValue(p, p.Default); err == nil {
+				s["default"] = v
+			}
+		}
+
+		if p.Required && p.Default == nil {
+			required = append(required, p.Name)
+		}
+
+		properties[p.Name] = s
+	}
+
+	return map[string]interface{}{
+		"title":                t.Type,
+		"description":          t.Description,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
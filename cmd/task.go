@@ -0,0 +1,278 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// task is the condensed form of an xldeploy task as returned by the tasks/v2 api
+type task struct {
+	ID             string            `json:"id"`
+	Description    string            `json:"description"`
+	State          string            `json:"state"`
+	Owner          string            `json:"owner"`
+	StartDate      string            `json:"startDate,omitempty"`
+	CompletionDate string            `json:"completionDate,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Block          *taskBlock        `json:"block,omitempty"`
+}
+
+// taskBlock is a (composite or step) block within a task
+type taskBlock struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	State       string      `json:"state"`
+	Blocks      []taskBlock `json:"blocks,omitempty"`
+	Steps       []taskStep  `json:"steps,omitempty"`
+}
+
+// taskStep is a single step within a step block
+type taskStep struct {
+	Description    string `json:"description"`
+	State          string `json:"state"`
+	FailureCount   int    `json:"failureCount"`
+	StartDate      string `json:"startDate,omitempty"`
+	CompletionDate string `json:"completionDate,omitempty"`
+	Log            string `json:"log,omitempty"`
+}
+
+// stepBlockState is what xldeploy returns when asking for the steps of a step block
+type stepBlockState struct {
+	Steps []taskStep `json:"steps"`
+}
+
+// taskCmd represents the task command
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "handle xldeploy tasks",
+	Long:  `list, inspect and control the tasks running in xldeploy`,
+}
+
+var taskListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the active tasks of the current user",
+	Run:   listTasks,
+}
+
+var taskShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show a task with its blocks and steps",
+	Long:  "usage: show <task id>",
+	Run:   showTask,
+}
+
+var taskStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "start or resume a task",
+	Long:  "usage: start <task id>",
+	Run:   controlTask("start"),
+}
+
+var taskStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "gracefully stop a running task",
+	Long:  "usage: stop <task id>",
+	Run:   controlTask("stop"),
+}
+
+var taskAbortCmd = &cobra.Command{
+	Use:   "abort",
+	Short: "abort a running task",
+	Long:  "usage: abort <task id>",
+	Run:   controlTask("abort"),
+}
+
+var taskCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "cancel a stopped or failed task",
+	Long:  "usage: cancel <task id>",
+	Run:   controlTask("cancel"),
+}
+
+var taskArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "archive an executed task",
+	Long:  "usage: archive <task id>",
+	Run:   controlTask("archive"),
+}
+
+func init() {
+	taskCmd.AddCommand(taskListCmd)
+	taskCmd.AddCommand(taskShowCmd)
+	taskCmd.AddCommand(taskStartCmd)
+	taskCmd.AddCommand(taskStopCmd)
+	taskCmd.AddCommand(taskAbortCmd)
+	taskCmd.AddCommand(taskCancelCmd)
+	taskCmd.AddCommand(taskArchiveCmd)
+
+	RootCmd.AddCommand(taskCmd)
+}
+
+// finalTaskStates are the states in which a task no longer changes by itself
+var finalTaskStates = map[string]bool{
+	"EXECUTED":  true,
+	"DONE":      true,
+	"FAILED":    true,
+	"STOPPED":   true,
+	"ABORTED":   true,
+	"CANCELLED": true,
+}
+
+func listTasks(cmd *cobra.Command, args []string) {
+	var tl []task
+
+	api := getAPIClient()
+
+	err := api.do(http.MethodGet, "tasks/v2/current", nil, nil, &tl)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error retrieving tasks: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	RenderJSON(tl)
+}
+
+func showTask(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a task id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	t, err := getTask(api, args[0])
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error retrieving task %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	if t.Block != nil {
+		if err := fillSteps(api, t.ID, t.Block); err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error retrieving steps for task %s: %s", cmd.CommandPath(), args[0], err)
+			os.Exit(1)
+		}
+	}
+
+	RenderJSON(t)
+}
+
+// controlTask returns a command function performing the given action on a task
+func controlTask(action string) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		var err error
+
+		if len(args) != 1 {
+			jww.FATAL.Printf("%s: requires a task id", cmd.CommandPath())
+			os.Exit(1)
+		}
+
+		api := getAPIClient()
+
+		switch action {
+		case "cancel":
+			err = api.do(http.MethodDelete, "tasks/v2/"+args[0], nil, nil, nil)
+		default:
+			err = api.do(http.MethodPost, "tasks/v2/"+args[0]+"/"+action, nil, nil, nil)
+		}
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error performing %s on task %s: %s", cmd.CommandPath(), action, args[0], err)
+			os.Exit(1)
+		}
+
+		// cancelled and archived tasks are no longer active, so there is nothing left to show
+		if action == "cancel" || action == "archive" {
+			fmt.Printf("task %s: %s done\n", args[0], action)
+			return
+		}
+
+		t, err := getTask(api, args[0])
+		if err != nil {
+			jww.FATAL.Printf("%s: encounterd a fatal error retrieving task %s: %s", cmd.CommandPath(), args[0], err)
+			os.Exit(1)
+		}
+
+		RenderJSON(t)
+	}
+}
+
+// fillSteps walks the block tree of a task and retrieves the steps of every step block
+func fillSteps(api *apiClient, id string, b *taskBlock) error {
+	if len(b.Blocks) == 0 {
+		steps, err := getSteps(api, id, b.ID)
+		if err != nil {
+			return err
+		}
+		b.Steps = steps
+		return nil
+	}
+
+	for i := range b.Blocks {
+		if err := fillSteps(api, id, &b.Blocks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSteps(api *apiClient, id, blockID string) ([]taskStep, error) {
+	var sb stepBlockState
+	err := api.do(http.MethodGet, "tasks/v2/"+id+"/block/"+blockID+"/step", nil, nil, &sb)
+	return sb.Steps, err
+}
+
+func getTask(api *apiClient, id string) (task, error) {
+	var t task
+	err := api.do(http.MethodGet, "tasks/v2/"+id, nil, nil, &t)
+	return t, err
+}
+
+func startTask(api *apiClient, id string) error {
+	return api.do(http.MethodPost, "tasks/v2/"+id+"/start", nil, nil, nil)
+}
+
+func archiveTask(api *apiClient, id string) error {
+	return api.do(http.MethodPost, "tasks/v2/"+id+"/archive", nil, nil, nil)
+}
+
+// waitForTask follows a task until it has reached one of the final states, reporting progress on stderr
+// executed tasks are archived so they show up in the deployment history
+func waitForTask(api *apiClient, id string) (task, error) {
+	t, err := watchTask(api, id, os.Stderr)
+	if err != nil {
+		return t, err
+	}
+
+	if t.State == "EXECUTED" {
+		if err := archiveTask(api, id); err != nil {
+			return t, err
+		}
+		t.State = "DONE"
+	}
+
+	return t, nil
+}
@@ -0,0 +1,130 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var repositoryTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "display a part of the repository as a tree",
+	Long:  "usage: tree <root id> [--depth <n>] [--json]",
+	Run:   TreeCI,
+}
+
+// ciNode is a ci with its children as found when walking the repository
+type ciNode struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Children []ciNode `json:"children,omitempty"`
+}
+
+var depth int
+
+func init() {
+	repositoryTreeCmd.Flags().IntVarP(&depth, "depth", "d", 0, "maximum depth to descend, 0 descends all the way")
+	repositoryTreeCmd.Flags().BoolVar(&jsonBool, "json", false, "render the tree as json")
+
+	repositoryCmd.AddCommand(repositoryTreeCmd)
+}
+
+func TreeCI(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires on argument", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	root, err := readCI(api, args[0])
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error in retrieving Configuration item %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	t, _ := root["type"].(string)
+	n, err := walkTree(api, args[0], t, depth)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error walking %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	if jsonBool {
+		RenderJSON(n)
+		return
+	}
+
+	fmt.Printf("%s [%s]\n", n.ID, n.Type)
+	count := printTree(os.Stdout, n.Children, "")
+	fmt.Printf("\n%d cis\n", count)
+}
+
+// walkTree collects the children of a ci recursively, max limits the number of levels below id (0 is unlimited)
+func walkTree(api *apiClient, id, t string, max int) (ciNode, error) {
+	n := ciNode{ID: id, Type: t}
+
+	refs, err := queryCIs(api, url.Values{"parent": {id}, "resultsPerPage": {"-1"}})
+	if err != nil {
+		return n, err
+	}
+
+	for _, r := range refs {
+		c := ciNode{ID: r.ID, Type: r.Type}
+		if max != 1 {
+			next := max
+			if next > 0 {
+				next--
+			}
+			c, err = walkTree(api, r.ID, r.Type, next)
+			if err != nil {
+				return n, err
+			}
+		}
+		n.Children = append(n.Children, c)
+	}
+
+	return n, nil
+}
+
+// printTree writes nodes like the unix tree command does and returns the number of nodes written
+func printTree(out io.Writer, nodes []ciNode, prefix string) int {
+	count := 0
+
+	for i, n := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		fmt.Fprintf(out, "%s%s%s [%s]\n", prefix, branch, path.Base(n.ID), n.Type)
+		count += 1 + printTree(out, n.Children, prefix+indent)
+	}
+
+	return count
+}
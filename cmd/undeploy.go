@@ -0,0 +1,114 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// undeployCmd represents the undeploy command
+var undeployCmd = &cobra.Command{
+	Use:   "undeploy",
+	Short: "undeploy an application from an environment",
+	Long:  "usage: undeploy <deployed application id> [--orchestrator <name>]...",
+	Run:   undeploy,
+}
+
+var orchestrators []string
+
+func init() {
+	undeployCmd.Flags().BoolVarP(&waitBool, "wait", "w", false, "wait for the undeployment task to finish")
+	undeployCmd.Flags().DurationVar(&pollInterval, "interval", 2*time.Second, "time between two polls of the task state")
+	undeployCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "maximum time to wait for the task, 0 waits forever")
+	undeployCmd.Flags().StringSliceVar(&orchestrators, "orchestrator", nil, "orchestrator(s) to use for the undeployment")
+
+	RootCmd.AddCommand(undeployCmd)
+}
+
+func undeploy(cmd *cobra.Command, args []string) {
+	var dep deployment
+
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a deployed application id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	if err := checkOrchestrators(orchestrators); err != nil {
+		jww.FATAL.Printf("%s: %s", cmd.CommandPath(), err)
+		os.Exit(1)
+	}
+
+	api := getAPIClient()
+
+	err := api.do(http.MethodGet, "deployment/prepare/undeploy", url.Values{"deployedApplication": {args[0]}}, nil, &dep)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error preparing the undeployment of %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	// the deployment carries the deployed application under application, like the <application> element in xml
+	if len(orchestrators) != 0 {
+		da, ok := dep["application"].(map[string]interface{})
+		if !ok {
+			jww.FATAL.Printf("%s: undeployment of %s has no deployed application to set orchestrators on", cmd.CommandPath(), args[0])
+			os.Exit(1)
+		}
+		da["orchestrator"] = orchestrators
+	}
+
+	runDeploymentTask(cmd, api, dep)
+}
+
+// checkOrchestrators verifies that all given orchestrators are known to xldeploy
+func checkOrchestrators(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	xld := GetClient()
+
+	known, err := xld.Metadata.GetOrchestrators()
+	if err != nil {
+		return fmt.Errorf("encounterd a fatal error while retrieving orchestrators: %s", err)
+	}
+
+	for _, n := range names {
+		found := false
+		for _, k := range known {
+			if k == n {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown orchestrator %s, see metadata orchestrators for the available ones", n)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var taskWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "follow a task step by step until it is finished",
+	Long:  "usage: watch <task id>\nprints every step state change and the step logs as they grow, exits non zero when the task does not end successfully",
+	Run:   watchTaskCmd,
+}
+
+var pollInterval time.Duration
+var waitTimeout time.Duration
+
+func init() {
+	taskWatchCmd.Flags().DurationVar(&pollInterval, "interval", 2*time.Second, "time between two polls of the task state")
+	taskWatchCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "maximum time to watch the task, 0 watches forever")
+
+	taskCmd.AddCommand(taskWatchCmd)
+}
+
+func watchTaskCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		jww.FATAL.Printf("%s: requires a task id", cmd.CommandPath())
+		os.Exit(1)
+	}
+
+	t, err := watchTask(getAPIClient(), args[0], os.Stdout)
+	if err != nil {
+		jww.FATAL.Printf("%s: encounterd a fatal error watching task %s: %s", cmd.CommandPath(), args[0], err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("task %s finished in state %s\n", t.ID, t.State)
+
+	if t.State != "EXECUTED" && t.State != "DONE" {
+		os.Exit(1)
+	}
+}
+
+// taskWatcher remembers what has been reported of a task so only changes are printed
+type taskWatcher struct {
+	api      *apiClient
+	id       string
+	out      io.Writer
+	blocks   map[string]string
+	steps    map[string]string
+	logs     map[string]int
+	deadline time.Time
+}
+
+// watchTask polls a task until it reaches one of the final states or waitTimeout has passed
+// the timeout also applies to the requests themselves, so a hanging connection cannot keep it waiting
+// step state changes and new log output are written to out as plain lines, so it works without a terminal
+func watchTask(api *apiClient, id string, out io.Writer) (task, error) {
+	w := taskWatcher{
+		api:    api,
+		id:     id,
+		out:    out,
+		blocks: make(map[string]string),
+		steps:  make(map[string]string),
+		logs:   make(map[string]int),
+	}
+
+	if waitTimeout > 0 {
+		w.deadline = time.Now().Add(waitTimeout)
+		w.api = api.withDeadline(w.deadline)
+	}
+
+	state := ""
+	for {
+		t, err := getTask(w.api, id)
+		if err != nil {
+			return t, w.timedOut(state, err)
+		}
+
+		if t.State != state {
+			fmt.Fprintf(out, "task %s: %s\n", id, t.State)
+			state = t.State
+		}
+
+		if t.Block != nil {
+			if err := w.report(t.Block); err != nil {
+				return t, w.timedOut(state, err)
+			}
+		}
+
+		if finalTaskStates[t.State] {
+			return t, nil
+		}
+
+		if waitTimeout > 0 && time.Now().After(w.deadline) {
+			return t, fmt.Errorf("task is still %s after %s", t.State, waitTimeout)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// timedOut replaces err by a timeout error when the request failed because waitTimeout has passed
+func (w *taskWatcher) timedOut(state string, err error) error {
+	if waitTimeout > 0 && time.Now().After(w.deadline) {
+		return fmt.Errorf("task is still %s after %s", state, waitTimeout)
+	}
+	return err
+}
+
+// report walks the block tree and prints the steps of every step block that changed since the last poll
+func (w *taskWatcher) report(b *taskBlock) error {
+	if len(b.Blocks) != 0 {
+		for i := range b.Blocks {
+			if err := w.report(&b.Blocks[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// a block that has not started, or that we have already seen finish, has nothing new to tell
+	prev, seen := w.blocks[b.ID]
+	w.blocks[b.ID] = b.State
+	if b.State == "PENDING" || (seen && prev == b.State && b.State == "DONE") {
+		return nil
+	}
+
+	steps, err := getSteps(w.api, w.id, b.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range steps {
+		key := fmt.Sprintf("%s_%d", b.ID, i+1)
+
+		if w.steps[key] != s.State {
+			fmt.Fprintf(w.out, "[%s] step %d/%d %s: %s\n", b.Description, i+1, len(steps), s.State, s.Description)
+			w.steps[key] = s.State
+		}
+
+		if len(s.Log) > w.logs[key] {
+			for _, l := range strings.Split(strings.TrimRight(s.Log[w.logs[key]:], "\n"), "\n") {
+				fmt.Fprintf(w.out, "    %s\n", l)
+			}
+			w.logs[key] = len(s.Log)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright © 2017 Roy Kliment <roy.kliment@cinqict.nl>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/viveleroy/goxldeploy"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// wizard asks for the properties of a ci on the terminal
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	api *apiClient
+}
+
+// newWizard returns a wizard reading from stdin, the questions go to stderr so stdout only holds the result
+func newWizard() wizard {
+	return wizard{in: bufio.NewReader(os.Stdin), out: os.Stderr, api: getAPIClient()}
+}
+
+// create walks through the properties of a type, category by category, and returns the ci as id, type and properties
+func (w wizard) create(t goxldeploy.Type, ciID string) (map[string]interface{}, error) {
+	spec := map[string]interface{}{"id": ciID, "type": t.Type}
+
+	fmt.Fprintf(w.out, "%s: %s\n", t.Type, t.Description)
+
+	for ciID == "" {
+		var err error
+		ciID, err = w.ask("id")
+		if err != nil {
+			return nil, err
+		}
+		spec["id"] = ciID
+	}
+
+	// categories are presented in the order in which they first appear in the metadata
+	var categories []string
+	byCategory := make(map[string][]goxldeploy.PropertyDescriptor)
+	for _, p := range t.Properties {
+		// the containing ci follows from the id, contained sets and lists are embedded cis and not references
+		if p.AsContainment {
+			switch strings.ToUpper(p.Kind) {
+			case "CI", "SET_OF_CI", "LIST_OF_CI":
+				continue
+			}
+		}
+		if _, ok := byCategory[p.Category]; !ok {
+			categories = append(categories, p.Category)
+		}
+		byCategory[p.Category] = append(byCategory[p.Category], p)
+	}
+
+	for _, c := range categories {
+		fmt.Fprintf(w.out, "\n== %s ==\n", c)
+
+		for _, p := range byCategory[c] {
+			v, err := w.property(p)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				spec[p.Name] = v
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+// property asks for a single property and returns nil when it is left empty
+func (w wizard) property(p goxldeploy.PropertyDescriptor) (interface{}, error) {
+	fmt.Fprintln(w.out)
+	if p.Description != "" {
+		fmt.Fprintf(w.out, "%s\n", p.Description)
+	}
+
+	label := fmt.Sprintf("%s (%s", p.Name, strings.ToLower(p.Kind))
+	if p.Required {
+		label += ", required"
+	}
+	if p.Default != nil {
+		label += fmt.Sprintf(", default %v", p.Default)
+	}
+	label += ")"
+
+	// the choices for a reference are looked up once, when that fails the ids have to be typed
+	kind := strings.ToUpper(p.Kind)
+	var refs []ciRef
+	if kind == "CI" || kind == "SET_OF_CI" || kind == "LIST_OF_CI" {
+		var err error
+		refs, err = queryCIs(w.api, url.Values{"type": {p.ReferencedType}, "resultsPerPage": {"-1"}})
+		if err != nil {
+			fmt.Fprintf(w.out, "can not list the %s choices: %s\n", p.ReferencedType, err)
+		}
+	}
+
+	for {
+		var v interface{}
+		var err error
+
+		switch {
+		case p.Password:
+			v, err = w.askPassword(label)
+		case kind == "ENUM":
+			fmt.Fprintf(w.out, "one of: %s\n", strings.Join(p.EnumValues, ", "))
+			v, err = w.ask(label)
+		case kind == "CI" || kind == "SET_OF_CI" || kind == "LIST_OF_CI":
+			v, err = w.askReference(label, p.ReferencedType, refs, kind != "CI")
+		case kind == "SET_OF_STRING" || kind == "LIST_OF_STRING":
+			v, err = w.askList(label + " a,b,c")
+		case kind == "MAP_STRING_STRING":
+			v, err = w.askMap(label + " k:v,k2:v2")
+		default:
+			v, err = w.ask(label)
+		}
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			fmt.Fprintln(w.out, err)
+			continue
+		}
+
+		if empty(v) {
+			if p.Required && p.Default == nil {
+				fmt.Fprintln(w.out, "this property is required")
+				continue
+			}
+			return nil, nil
+		}
+
+		cv, err := coerceValue(p, v)
+		if err != nil {
+			fmt.Fprintln(w.out, err)
+			continue
+		}
+		return cv, nil
+	}
+}
+
+func (w wizard) ask(label string) (string, error) {
+	fmt.Fprintf(w.out, "%s: ", label)
+	l, err := w.in.ReadString('\n')
+	if err != nil && l == "" {
+		return "", err
+	}
+	return strings.TrimSpace(l), nil
+}
+
+// askPassword reads without echo when stdin is a terminal
+func (w wizard) askPassword(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return w.ask(label)
+	}
+
+	fmt.Fprintf(w.out, "%s: ", label)
+	b, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(w.out)
+	return string(b), err
+}
+
+func (w wizard) askList(label string) ([]string, error) {
+	l, err := w.ask(label)
+	if err != nil || l == "" {
+		return nil, err
+	}
+	pp := &propertyParser{s: l + "]"}
+	v, err := pp.list()
+	if err == nil && !pp.done() {
+		err = pp.errorf("unexpected input after the list")
+	}
+	return v, err
+}
+
+func (w wizard) askMap(label string) (map[string]string, error) {
+	l, err := w.ask(label)
+	if err != nil || l == "" {
+		return nil, err
+	}
+	pp := &propertyParser{s: l + "}"}
+	v, err := pp.dict()
+	if err == nil && !pp.done() {
+		err = pp.errorf("unexpected input after the map")
+	}
+	return v, err
+}
+
+// askReference offers the cis of the referenced type to choose from, by number or by id
+// without refs only ids are accepted
+func (w wizard) askReference(label, referencedType string, refs []ciRef, many bool) (interface{}, error) {
+	for i, r := range refs {
+		fmt.Fprintf(w.out, "  %3d) %s\n", i+1, r.ID)
+	}
+
+	if many {
+		label += " numbers or ids separated by ,"
+	}
+
+	l, err := w.ask(label)
+	if err != nil || l == "" {
+		return nil, err
+	}
+
+	var ids []string
+	for _, c := range strings.Split(l, ",") {
+		c = strings.TrimSpace(c)
+		if n, err := strconv.Atoi(c); err == nil && len(refs) != 0 {
+			if n < 1 || n > len(refs) {
+				return nil, fmt.Errorf("%d is not one of the choices", n)
+			}
+			c = refs[n-1].ID
+		}
+		ids = append(ids, c)
+	}
+
+	if !many {
+		if len(ids) != 1 {
+			return nil, fmt.Errorf("choose a single %s", referencedType)
+		}
+		return ids[0], nil
+	}
+	return ids, nil
+}
+
+// confirm asks a yes or no question, anything but yes is no
+func (w wizard) confirm(question string) bool {
+	a, err := w.ask(question + " [y/N]")
+	if err != nil {
+		return false
+	}
+	a = strings.ToLower(a)
+	return a == "y" || a == "yes"
+}
+
+func empty(v interface{}) bool {
+	switch e := v.(type) {
+	case nil:
+		return true
+	case string:
+		return e == ""
+	case []string:
+		return len(e) == 0
+	case map[string]string:
+		return len(e) == 0
+	}
+	return false
+}